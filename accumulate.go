@@ -0,0 +1,196 @@
+package pipe
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is an integer counter safe for concurrent use, share it between Parallel jobs to aggregate totals.
+// Zero value is ready to use.
+type Counter struct {
+	n atomic.Int64
+}
+
+// Add adds delta to the counter and returns the new value.
+func (c *Counter) Add(delta int64) int64 {
+	return c.n.Add(delta)
+}
+
+// Inc increments the counter and returns the new value.
+func (c *Counter) Inc() int64 {
+	return c.n.Add(1)
+}
+
+// Load returns the current value.
+func (c *Counter) Load() int64 {
+	return c.n.Load()
+}
+
+// Reset sets the counter to zero and returns the previous value.
+func (c *Counter) Reset() int64 {
+	return c.n.Swap(0)
+}
+
+// Gauge holds the last value of T safe for concurrent use. Zero value is ready to use.
+type Gauge[T any] struct {
+	mu    sync.Mutex
+	value T
+}
+
+// Set replaces the value.
+func (g *Gauge[T]) Set(v T) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.value = v
+}
+
+// Load returns the current value.
+func (g *Gauge[T]) Load() T {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.value
+}
+
+// Update replaces the value with the result of fn called with the current one and returns it.
+// Other updates wait until fn returns, so fn must be short.
+func (g *Gauge[T]) Update(fn func(v T) T) T {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.value = fn(g.value)
+
+	return g.value
+}
+
+// SetOnce holds a value which may be set only once, like the first found match. Zero value is ready to use.
+type SetOnce[T any] struct {
+	mu    sync.Mutex
+	set   bool
+	value T
+}
+
+// Set stores v unless a value is already set, it reports whether v was stored.
+func (s *SetOnce[T]) Set(v T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.set {
+		return false
+	}
+
+	s.value, s.set = v, true
+
+	return true
+}
+
+// Get returns the value, ok is false when it is not set yet.
+func (s *SetOnce[T]) Get() (v T, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.value, s.set
+}
+
+// ConcurrentMap is a map safe for concurrent use. Zero value is ready to use.
+type ConcurrentMap[K comparable, V any] struct {
+	mu     sync.RWMutex
+	values map[K]V
+}
+
+// Load returns value stored by key, ok is false when there is no such key.
+func (m *ConcurrentMap[K, V]) Load(key K) (value V, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	value, ok = m.values[key]
+
+	return value, ok
+}
+
+// Store saves value by key.
+func (m *ConcurrentMap[K, V]) Store(key K, value V) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.init()
+	m.values[key] = value
+}
+
+// LoadOrStore returns value stored by key or stores and returns value, loaded reports whether it was stored before.
+func (m *ConcurrentMap[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if actual, loaded = m.values[key]; loaded {
+		return actual, true
+	}
+
+	m.init()
+	m.values[key] = value
+
+	return value, false
+}
+
+// Update replaces value stored by key with the result of fn and returns it, ok is false for a missing key.
+// Other writers wait until fn returns, so fn must be short.
+func (m *ConcurrentMap[K, V]) Update(key K, fn func(value V, ok bool) V) V {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.init()
+
+	value, ok := m.values[key]
+	value = fn(value, ok)
+	m.values[key] = value
+
+	return value
+}
+
+// Delete removes key.
+func (m *ConcurrentMap[K, V]) Delete(key K) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.values, key)
+}
+
+// Len returns the number of keys.
+func (m *ConcurrentMap[K, V]) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.values)
+}
+
+// Range calls fn for every key until it returns false. The map is locked for reading while fn runs.
+func (m *ConcurrentMap[K, V]) Range(fn func(key K, value V) bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for k, v := range m.values {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+// Snapshot returns a copy of the map.
+func (m *ConcurrentMap[K, V]) Snapshot() map[K]V {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	values := make(map[K]V, len(m.values))
+	for k, v := range m.values {
+		values[k] = v
+	}
+
+	return values
+}
+
+func (m *ConcurrentMap[K, V]) init() {
+	if m.values == nil {
+		m.values = map[K]V{}
+	}
+}
@@ -0,0 +1,114 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotStarted is returned by the await handler of Async when its start handler was not executed before.
+var ErrNotStarted = errors.New("pipeline: async stage was not started")
+
+// ErrAsyncStarted is returned by the start handler of Async called again before the await handler has taken
+// the result of the previous call.
+var ErrAsyncStarted = errors.New("pipeline: async stage is already started")
+
+// Future is a result of handler running in background.
+type Future[T any] struct {
+	done chan struct{}
+	out  T
+	err  error
+}
+
+// Start runs handler in a separated routine and returns its future result.
+func Start[T any](ctx context.Context, handler HandlerFunc[T], in T) *Future[T] {
+	f := &Future[T]{done: make(chan struct{})}
+
+	spawn(ctx, func() {
+		defer close(f.done)
+		f.out, f.err = call(ctx, handler, in)
+	})
+
+	return f
+}
+
+// Wait blocks until the handler is finished or ctx is done.
+func (f *Future[T]) Wait(ctx context.Context) (out T, err error) {
+	select {
+	case <-ctx.Done():
+		return out, cause(ctx)
+	case <-f.done:
+		return f.out, f.err
+	}
+}
+
+// Done returns channel which is closed when the handler is finished.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Async splits handler into a pair of stages of the same pipeline.
+// The start stage launches handler with its input in background and passes the input further unchanged,
+// so stages between start and await run concurrently with handler.
+// The await stage waits for the handler and passes merge(in, result) further,
+// if merge is nil the handler result is passed as is.
+// Handler still running when Execute returns is canceled and waited for.
+// The pair handles a single value at a time within a run: calling start again before await, e.g. for elements
+// by ForEach, fails with ErrAsyncStarted; use Start in such stages.
+func Async[T any](handler HandlerFunc[T], merge func(in, result T) T) (start, await HandlerFunc[T]) {
+	key := new(struct{ byte })
+
+	start = func(ctx context.Context, in T) (out T, err error) {
+		s := scopeFrom(ctx)
+		if s == nil || s.shared {
+			return out, errNoScope
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+
+		var f *Future[T]
+
+		if v := s.loadOrStore(key, func() any { f = Start(ctx, handler, in); return f }); v != any(f) {
+			cancel()
+			return out, ErrAsyncStarted
+		}
+
+		s.onClose(func() error {
+			cancel()
+			<-f.done
+
+			return nil
+		})
+
+		return in, nil
+	}
+
+	await = func(ctx context.Context, in T) (out T, err error) {
+		s := scopeFrom(ctx)
+		if s == nil || s.shared {
+			return out, errNoScope
+		}
+
+		v, ok := s.load(key)
+		if !ok {
+			return out, ErrNotStarted
+		}
+
+		s.delete(key)
+
+		result, err := v.(*Future[T]).Wait(ctx)
+		if err != nil {
+			return out, err
+		}
+
+		if merge == nil {
+			return result, nil
+		}
+
+		return merge(in, result), nil
+	}
+
+	start = describe(start, describeInfo{middleware: "AsyncStart", inner: handler})
+	await = describe(await, describeInfo{middleware: "AsyncAwait", inner: handler})
+
+	return start, await
+}
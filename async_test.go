@@ -0,0 +1,51 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAsync(t *testing.T) {
+	double := func(ctx context.Context, in int) (int, error) { return in * 2, nil }
+	sum := func(in, result int) int { return in + result }
+
+	start, await := Async(double, sum)
+	inc := func(ctx context.Context, in int) (int, error) { return in + 1, nil }
+
+	tests := []struct {
+		name     string
+		pipeline Pipeline[int]
+		want     int
+		err      error
+	}{
+		{name: "pair", pipeline: Pipeline[int]{start, inc, await}, want: 3 + 4},
+		{name: "repeated", pipeline: Pipeline[int]{start, await, start, await}, want: 6 + 12},
+		{name: "started twice", pipeline: Pipeline[int]{start, start, await}, err: ErrAsyncStarted},
+		{name: "not started", pipeline: Pipeline[int]{await}, err: ErrNotStarted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer checkLeaks(t)()
+
+			out, err := Execute(context.Background(), tt.pipeline, 2)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			if tt.err == nil && out != tt.want {
+				t.Fatalf("got %d, want %d", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestAsyncForEach(t *testing.T) {
+	start, await := Async(func(ctx context.Context, in int) (int, error) { return in, nil }, nil)
+
+	_, err := Execute(context.Background(), Pipeline[[]int]{ForEach(start), ForEach(await)}, []int{1, 2})
+	if !errors.Is(err, ErrAsyncStarted) {
+		t.Fatalf("got error %v, want ErrAsyncStarted", err)
+	}
+}
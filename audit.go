@@ -0,0 +1,86 @@
+package pipe
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Outcomes of AuditRecord.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditRecord describes a value which has passed a stage.
+type AuditRecord struct {
+	Time  time.Time
+	Stage int
+	// InputHash identifies the stage input without keeping it.
+	InputHash string
+	Duration  time.Duration
+	// Outcome is AuditSuccess or AuditFailure.
+	Outcome string
+	// Err is the message of the stage error.
+	Err           string
+	CorrelationID string
+	// Meta is the snapshot of the run or element metadata, put the actor there to record who initiated the run.
+	Meta map[string]any
+}
+
+// AuditSink appends audit records to an append-only storage.
+type AuditSink interface {
+	Append(ctx context.Context, r AuditRecord) error
+}
+
+// AuditFunc is a function implementing AuditSink.
+type AuditFunc func(ctx context.Context, r AuditRecord) error
+
+func (f AuditFunc) Append(ctx context.Context, r AuditRecord) error {
+	return f(ctx, r)
+}
+
+// Audit returns hook appending a record to sink every time a stage has handled a value.
+// Inputs of type T are identified by hash, the others and all inputs when hash is nil
+// by SHA-256 of their Go-syntax representation. Failed append cancels the run with its error,
+// so values are not processed without audit: the run fails with it even when the last stage is not recorded.
+func Audit[T any](sink AuditSink, hash func(T) string) Hook {
+	fn := func(ctx context.Context, e Event) {
+		if e.Kind != EventStageDone {
+			return
+		}
+
+		r := AuditRecord{
+			Time:          e.Time,
+			Stage:         e.Stage,
+			Duration:      e.Duration,
+			Outcome:       AuditSuccess,
+			CorrelationID: e.CorrelationID,
+			Meta:          e.Meta,
+		}
+
+		if v, ok := e.In.(T); ok && hash != nil {
+			r.InputHash = hash(v)
+		} else {
+			r.InputHash = hashValue(e.In)
+		}
+
+		if e.Err != nil {
+			r.Outcome = AuditFailure
+			r.Err = e.Err.Error()
+		}
+
+		if err := sink.Append(ctx, r); err != nil {
+			Cancel(ctx, fmt.Errorf("pipeline: audit: %w", err))
+		}
+	}
+
+	return fn
+}
+
+func hashValue(v any) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%#v", v)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,85 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAuditFailure(t *testing.T) {
+	errSink := errors.New("sink is down")
+
+	inc := func(ctx context.Context, in int) (int, error) { return in + 1, nil }
+	skip := func(ctx context.Context, in int) (int, error) { return in, ErrSkipRest }
+
+	tests := []struct {
+		name     string
+		pipeline Pipeline[int]
+		failAt   int
+		stage    int
+	}{
+		{name: "first stage", pipeline: Pipeline[int]{inc, inc, inc}, failAt: 0, stage: 1},
+		{name: "last stage", pipeline: Pipeline[int]{inc, inc, inc}, failAt: 2, stage: 2},
+		{name: "skip rest", pipeline: Pipeline[int]{inc, skip, inc}, failAt: 1, stage: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var records []AuditRecord
+
+			sink := func(ctx context.Context, r AuditRecord) error {
+				records = append(records, r)
+
+				if r.Stage == tt.failAt {
+					return errSink
+				}
+
+				return nil
+			}
+
+			ctx := WithHook(context.Background(), Audit[int](AuditFunc(sink), nil))
+
+			_, err := Execute(ctx, tt.pipeline, 0)
+			if !errors.Is(err, errSink) {
+				t.Fatalf("got error %v, want %v", err, errSink)
+			}
+
+			var se *StageError
+			if !errors.As(err, &se) || se.Stage != tt.stage {
+				t.Fatalf("got error %v, want *StageError of stage %d", err, tt.stage)
+			}
+
+			if n := len(records); n != tt.failAt+1 {
+				t.Fatalf("got %d records, want %d", n, tt.failAt+1)
+			}
+		})
+	}
+}
+
+func TestAuditSuccess(t *testing.T) {
+	var records []AuditRecord
+
+	sink := func(ctx context.Context, r AuditRecord) error {
+		records = append(records, r)
+		return nil
+	}
+
+	ctx := WithHook(context.Background(), Audit[int](AuditFunc(sink), func(v int) string { return "hash" }))
+
+	inc := func(ctx context.Context, in int) (int, error) { return in + 1, nil }
+
+	out, err := Execute(ctx, Pipeline[int]{inc, inc}, 0)
+	if err != nil || out != 2 {
+		t.Fatalf("got %d, %v, want 2, nil", out, err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+
+	for i, r := range records {
+		if r.Stage != i || r.Outcome != AuditSuccess || r.InputHash != "hash" {
+			t.Fatalf("got record %+v, want success of stage %d", r, i)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Package backoff provides delays between retries shared by retrying parts of pipe.
+package backoff
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Backoff returns delay before the attempt-th retry, attempt starts from 1.
+// prev is the delay returned for the previous retry, it is zero before the first retry.
+// Implementations must be safe for concurrent use.
+type Backoff interface {
+	Next(attempt int, prev time.Duration) time.Duration
+}
+
+// Func is a function implementing Backoff.
+type Func func(attempt int, prev time.Duration) time.Duration
+
+func (f Func) Next(attempt int, prev time.Duration) time.Duration {
+	return f(attempt, prev)
+}
+
+// Constant returns backoff waiting d before every retry.
+func Constant(d time.Duration) Backoff {
+	return Func(func(int, time.Duration) time.Duration {
+		return d
+	})
+}
+
+// Exponential returns backoff doubling delay after every retry starting from base, delays are capped by max.
+func Exponential(base, max time.Duration) Backoff {
+	return Func(func(attempt int, _ time.Duration) time.Duration {
+		return exponential(base, max, attempt)
+	})
+}
+
+// Seeded is implemented by randomized backoffs which may draw random numbers from the given source,
+// so delays are reproducible.
+type Seeded interface {
+	Backoff
+	// Seed returns copy of the backoff drawing random numbers from src.
+	Seed(src rand.Source) Backoff
+}
+
+// ExponentialJitter returns exponential backoff which delays are randomized between zero and the exponential
+// delay ("full jitter"), so retries of many clients do not come in waves. It implements Seeded.
+func ExponentialJitter(base, max time.Duration) Backoff {
+	return randomized{next: func(r *rng, attempt int, _ time.Duration) time.Duration {
+		return r.random(0, exponential(base, max, attempt))
+	}}
+}
+
+// Decorrelated returns backoff which delay is random between base and three times of the previous delay,
+// capped by max ("decorrelated jitter"). It implements Seeded.
+func Decorrelated(base, max time.Duration) Backoff {
+	return randomized{next: func(r *rng, _ int, prev time.Duration) time.Duration {
+		if prev < base {
+			prev = base
+		}
+
+		d := r.random(base, 3*prev)
+		if d > max {
+			d = max
+		}
+
+		return d
+	}}
+}
+
+// randomized is a backoff drawing random numbers from r, nil r means the global source of math/rand.
+type randomized struct {
+	next func(r *rng, attempt int, prev time.Duration) time.Duration
+	r    *rng
+}
+
+func (b randomized) Next(attempt int, prev time.Duration) time.Duration {
+	return b.next(b.r, attempt, prev)
+}
+
+func (b randomized) Seed(src rand.Source) Backoff {
+	return randomized{next: b.next, r: &rng{r: rand.New(src)}}
+}
+
+// rng is a source of random numbers safe for concurrent use.
+type rng struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func exponential(base, max time.Duration, attempt int) time.Duration {
+	d := float64(base) * math.Pow(2, float64(attempt-1))
+	if d > float64(max) {
+		return max
+	}
+
+	return time.Duration(d)
+}
+
+// random returns duration in [min, max].
+func (r *rng) random(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+
+	n := int64(max-min) + 1
+
+	if r == nil {
+		return min + time.Duration(rand.Int63n(n))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return min + time.Duration(r.r.Int63n(n))
+}
@@ -0,0 +1,132 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CacheStore keeps handler outputs by keys, implementations backed by external caches share outputs between runs
+// and processes.
+type CacheStore[K comparable, T any] interface {
+	// Get returns value stored by key, ok is false when there is no such key or it has expired.
+	Get(ctx context.Context, key K) (value T, ok bool, err error)
+	// Set stores value by key for ttl, zero ttl means no expiration.
+	Set(ctx context.Context, key K, value T, ttl time.Duration) error
+}
+
+// Cached returns handler passing further cached output of handler for inputs with the same key.
+// Outputs are cached for ttl, errors are not cached. Outputs returned with ErrSkipRest or ErrStop are cached
+// and the sentinel is returned, cache hits are passed further without it.
+func Cached[T any, K comparable](handler HandlerFunc[T], key func(T) K, ttl time.Duration, store CacheStore[K, T]) HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		k := key(in)
+
+		out, ok, err := store.Get(ctx, k)
+		if err != nil {
+			return out, err
+		}
+
+		if ok {
+			return out, nil
+		}
+
+		out, err = handler(ctx, in)
+		if err != nil && !finished(err) {
+			return out, err
+		}
+
+		if serr := store.Set(ctx, k, out, ttl); serr != nil {
+			return out, serr
+		}
+
+		return out, err
+	}
+
+	clone := func() any { return Cached(cloneHandler(handler), key, ttl, cloneStore(store)) }
+
+	return describe(fn, describeInfo{middleware: fmt.Sprintf("Cached(ttl=%s)", ttl), inner: handler, clone: clone})
+}
+
+// MemoryCache is CacheStore keeping values in memory. Expired values are removed lazily.
+// Zero value is ready to use.
+type MemoryCache[K comparable, T any] struct {
+	mu      sync.Mutex
+	entries map[K]cacheEntry[T]
+}
+
+type cacheEntry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+func (c *MemoryCache[K, T]) Get(ctx context.Context, key K) (value T, ok bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return value, false, nil
+	}
+
+	if !e.expires.IsZero() && !time.Now().Before(e.expires) {
+		delete(c.entries, key)
+		return value, false, nil
+	}
+
+	return e.value, true, nil
+}
+
+func (c *MemoryCache[K, T]) Set(ctx context.Context, key K, value T, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[K]cacheEntry[T]{}
+	}
+
+	e := cacheEntry[T]{value: value}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+
+	c.entries[key] = e
+
+	return nil
+}
+
+// Len returns number of stored values including expired but not yet removed ones.
+func (c *MemoryCache[K, T]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// Clone returns copy of the cache holding the same values, see Pipeline.Clone.
+func (c *MemoryCache[K, T]) Clone() *MemoryCache[K, T] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clone := &MemoryCache[K, T]{}
+
+	if c.entries != nil {
+		clone.entries = make(map[K]cacheEntry[T], len(c.entries))
+
+		for k, e := range c.entries {
+			clone.entries[k] = e
+		}
+	}
+
+	return clone
+}
+
+// cloneStore returns copy of store kept in memory of the process, other stores are shared.
+func cloneStore[K comparable, T any](store CacheStore[K, T]) CacheStore[K, T] {
+	if m, ok := store.(*MemoryCache[K, T]); ok {
+		return m.Clone()
+	}
+
+	return store
+}
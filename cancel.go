@@ -0,0 +1,62 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+)
+
+// Cancel stops the nearest run of Execute or Stream with cause.
+// Execute returns *StageError wrapping the cause before the next stage or after the last one,
+// Stream returns the cause. It does nothing outside of a run.
+func Cancel(ctx context.Context, cause error) {
+	if s := scopeFrom(ctx); s != nil && s.cancel != nil {
+		s.cancel(cause)
+	}
+}
+
+// cause returns the reason of ctx cancellation. The result matches ctx.Err() with errors.Is.
+func cause(ctx context.Context) error {
+	err := ctx.Err()
+
+	if c := context.Cause(ctx); c != nil && c != err {
+		return fmt.Errorf("%w: %w", err, c)
+	}
+
+	return err
+}
+
+// CheckCtx returns the reason of ctx cancellation or nil if ctx is not done.
+// Call it periodically from compute-bound handlers to bound their cancellation latency.
+func CheckCtx(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return cause(ctx)
+	default:
+		return nil
+	}
+}
+
+// Steps returns handler computing its result by calling step until it reports done,
+// ctx is checked between steps. Split CPU-heavy work into short steps, so the handler stops soon after cancellation.
+func Steps[T any](step func(ctx context.Context, v T) (next T, done bool, err error)) HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		for {
+			if err := CheckCtx(ctx); err != nil {
+				return out, err
+			}
+
+			next, done, err := step(ctx, in)
+			if err != nil {
+				return out, err
+			}
+
+			if done {
+				return next, nil
+			}
+
+			in = next
+		}
+	}
+
+	return fn
+}
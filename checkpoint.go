@@ -0,0 +1,84 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+)
+
+type checkpoint struct {
+	store StateStore
+	key   string
+	codec any
+}
+
+// Checkpoint makes Parallel save output of every completed batch into store under key,
+// so Parallel restarted after a crash with the same key, input and jobs executes only unfinished batches.
+// Checkpoints are deleted when Parallel succeeds.
+func Checkpoint[T any](store StateStore, key string, codec Codec[[]T]) ParallelOption {
+	return func(c *parallelConfig) {
+		c.checkpoint = &checkpoint{store: store, key: key, codec: codec}
+	}
+}
+
+func (c *checkpoint) batchKey(offset, n int) string {
+	return fmt.Sprintf("%s/%d-%d", c.key, offset, n)
+}
+
+// runBatch executes pipeline over batch or restores its output from checkpoint.
+// Stopped is set when a stage has returned ErrStop, the batch has succeeded then.
+func runBatch[T any](ctx context.Context, c *checkpoint, pipeline Pipeline[[]T], b *Batch[T], in []T, stopped *bool) (out []T, err error) {
+	if c == nil {
+		return executeStopped(ctx, pipeline, in, stopped)
+	}
+
+	codec, ok := c.codec.(Codec[[]T])
+	if !ok {
+		panic(fmt.Sprintf("checkpoint codec must be Codec[[]%T]!", *new(T)))
+	}
+
+	key := c.batchKey(b.Offset, b.Len)
+
+	data, ok, err := c.store.Load(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		if err := codec.Unmarshal(data, &out); err != nil {
+			return nil, err
+		}
+
+		return out, nil
+	}
+
+	out, err = executeStopped(ctx, pipeline, in, stopped)
+	if err != nil {
+		return out, err
+	}
+
+	data, err = codec.Marshal(out)
+	if err != nil {
+		return out, err
+	}
+
+	if err := c.store.Store(ctx, key, data); err != nil {
+		return out, err
+	}
+
+	return out, nil
+}
+
+// clearCheckpoint deletes checkpoints of batches.
+func clearCheckpoint[T any](ctx context.Context, c *checkpoint, batches []Batch[T]) error {
+	if c == nil {
+		return nil
+	}
+
+	for _, b := range batches {
+		if err := c.store.Delete(ctx, c.batchKey(b.Offset, b.Len)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
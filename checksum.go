@@ -0,0 +1,74 @@
+package pipe
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// DigestKey is the metadata key of the hex encoded digest set by Checksum.
+var DigestKey = NewMetaKey[string]("digest")
+
+// SHA256 is the hash of checksum stages, any func() hash.Hash like xxhash.New may be used instead.
+var SHA256 = sha256.New
+
+// ChecksumError is returned by Verify when the digest of a payload does not match the expected one.
+type ChecksumError struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("pipeline: checksum mismatch: expected %s, actual %s", e.Expected, e.Actual)
+}
+
+// Checksum returns stage storing hex encoded digest of its input computed with newHash into DigestKey metadata.
+// The payload is passed further unchanged.
+func Checksum(newHash func() hash.Hash) HandlerFunc[[]byte] {
+	fn := func(ctx context.Context, in []byte) (out []byte, err error) {
+		DigestKey.Set(ctx, digest(newHash, in))
+		return in, nil
+	}
+
+	return fn
+}
+
+// Verify returns stage failing with *ChecksumError when hex encoded digest of its input computed with newHash
+// differs from the one returned by expected. Nil expected means the digest stored by Checksum earlier.
+func Verify(newHash func() hash.Hash, expected func(ctx context.Context, in []byte) (string, error)) HandlerFunc[[]byte] {
+	if expected == nil {
+		expected = func(ctx context.Context, in []byte) (string, error) {
+			d, ok := DigestKey.Get(ctx)
+			if !ok {
+				return "", fmt.Errorf("pipeline: no %s in metadata", DigestKey.Name())
+			}
+
+			return d, nil
+		}
+	}
+
+	fn := func(ctx context.Context, in []byte) (out []byte, err error) {
+		want, err := expected(ctx, in)
+		if err != nil {
+			return out, err
+		}
+
+		if got := digest(newHash, in); !strings.EqualFold(got, want) {
+			return out, &ChecksumError{Expected: want, Actual: got}
+		}
+
+		return in, nil
+	}
+
+	return fn
+}
+
+func digest(newHash func() hash.Hash, data []byte) string {
+	h := newHash()
+	h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
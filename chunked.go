@@ -0,0 +1,121 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+)
+
+// ExecuteChunked runs pipeline for consecutive chunks of 'in' of at most chunkSize elements and concatenates results,
+// so intermediate slices of stages never hold the whole input at once.
+// The failure of a chunk stops processing and is returned as *BatchError, out holds results of the preceding chunks.
+// A stage returning ErrStop stops processing successfully, see ExecuteChunks.
+func ExecuteChunked[T any](ctx context.Context, pipeline Pipeline[[]T], in []T, chunkSize int) (out []T, err error) {
+	sink := func(ctx context.Context, chunk []T) error {
+		out = append(out, chunk...)
+		return nil
+	}
+
+	err = ExecuteChunks(ctx, pipeline, in, chunkSize, sink)
+
+	return out, err
+}
+
+// ExecuteChunks is like ExecuteChunked but passes result of every chunk to sink instead of keeping them,
+// so peak memory is bounded by a single chunk. When a stage returns ErrStop, the result of its chunk is passed
+// to sink and remaining chunks are not processed; a call from a stage passes ErrStop to the enclosing run.
+func ExecuteChunks[T any](ctx context.Context, pipeline Pipeline[[]T], in []T, chunkSize int, sink Sink[[]T]) error {
+	if chunkSize <= 0 {
+		panic("chunkSize value must be greater than zero!")
+	}
+
+	for i, beg := 0, 0; beg < len(in); i, beg = i+1, beg+chunkSize {
+		end := beg + chunkSize
+
+		if end > len(in) {
+			end = len(in)
+		}
+
+		stopped, err := executeChunk(ctx, pipeline, i, beg, in[beg:end], sink)
+		if err != nil {
+			return err
+		}
+
+		if stopped {
+			return stopResult(ctx)
+		}
+	}
+
+	return nil
+}
+
+// ExecuteSource is like ExecuteChunks but reads the input from src, so the whole input is never kept in memory.
+// ErrStop of a stage stops src too.
+func ExecuteSource[T any](ctx context.Context, pipeline Pipeline[[]T], src Source[T], chunkSize int, sink Sink[[]T]) error {
+	if chunkSize <= 0 {
+		panic("chunkSize value must be greater than zero!")
+	}
+
+	stopped := false
+
+	consume := func(ctx context.Context, values <-chan T) error {
+		i, offset := 0, 0
+		chunk := make([]T, 0, chunkSize)
+
+		flush := func() error {
+			stop, err := executeChunk(ctx, pipeline, i, offset, chunk, sink)
+			i, offset = i+1, offset+len(chunk)
+			chunk = make([]T, 0, chunkSize)
+
+			if err == nil && stop {
+				stopped = true
+				return errTaken
+			}
+
+			return err
+		}
+
+		for v := range values {
+			chunk = append(chunk, v)
+
+			if len(chunk) == chunkSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(chunk) == 0 || ctx.Err() != nil {
+			return nil
+		}
+
+		return flush()
+	}
+
+	if err := forward(ctx, src, consume); err != nil || !stopped {
+		return err
+	}
+
+	return stopResult(ctx)
+}
+
+// executeChunk runs pipeline for the chunk starting at offset and passes its result to sink.
+// Stopped is set when a stage has returned ErrStop.
+func executeChunk[T any](ctx context.Context, pipeline Pipeline[[]T], i, offset int, chunk []T, sink Sink[[]T]) (stopped bool, err error) {
+	out, err := executeStopped(ctx, pipeline, chunk, &stopped)
+	if err == nil {
+		err = sink(ctx, out)
+	}
+
+	if err != nil {
+		be := &BatchError{Batch: i, Offset: offset, Len: len(chunk), Stage: -1, Err: err}
+
+		var se *StageError
+		if errors.As(err, &se) {
+			be.Stage = se.Stage
+		}
+
+		return false, be
+	}
+
+	return stopped, nil
+}
@@ -0,0 +1,283 @@
+// Package cli runs pipelines of a pipe.Registry from the command line, see cmd/pipe.
+//
+// Values are exchanged with pipelines as JSON, so the pipelines must be registered with pipe.JSONCodec.
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/WinPooh32/pipe"
+)
+
+// Input formats.
+const (
+	// FormatJSON is a JSON array of values, or a sequence of JSON values like JSON lines.
+	FormatJSON = "json"
+	// FormatLines makes every line a JSON string value.
+	FormatLines = "lines"
+	// FormatCSV makes every record a JSON object keyed by the header record.
+	FormatCSV = "csv"
+)
+
+// Output formats.
+const (
+	// OutputJSON writes a JSON array of results.
+	OutputJSON = "json"
+	// OutputJSONLines writes a result per line.
+	OutputJSONLines = "jsonl"
+)
+
+// Main runs the command with arguments of the process and exits, it stops the run on interrupt.
+func Main(r *pipe.Registry) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+
+	err := Run(ctx, r, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+
+	stop()
+
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Run runs the command with args reading input from stdin unless a file is given.
+func Run(ctx context.Context, r *pipe.Registry, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("pipe", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	var (
+		list    = fs.Bool("list", false, "list registered pipelines")
+		file    = fs.String("in", "", "read input from file instead of stdin")
+		format  = fs.String("format", FormatJSON, "input format: json, lines or csv")
+		output  = fs.String("out", OutputJSON, "output format: json or jsonl")
+		jobs    = fs.Int("jobs", 1, "number of batches executed concurrently")
+		timeout = fs.Duration("timeout", 0, "stop the run after timeout, zero means no limit")
+	)
+
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: pipe [flags] pipeline")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *list {
+		for _, name := range r.Names() {
+			fmt.Fprintln(stdout, name)
+		}
+
+		return nil
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	if *jobs <= 0 {
+		return fmt.Errorf("pipe: jobs must be greater than zero")
+	}
+
+	if *output != OutputJSON && *output != OutputJSONLines {
+		return fmt.Errorf("pipe: unknown output format %q", *output)
+	}
+
+	in := stdin
+
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		in = f
+	}
+
+	values, err := read(in, *format)
+	if err != nil {
+		return err
+	}
+
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	out, err := execute(ctx, r, fs.Arg(0), values, *jobs)
+	if err != nil {
+		return err
+	}
+
+	return write(stdout, out, *output)
+}
+
+// execute runs pipeline name over values split into jobs batches.
+func execute(ctx context.Context, r *pipe.Registry, name string, values []json.RawMessage, jobs int) ([]json.RawMessage, error) {
+	codec := pipe.JSONCodec[[]json.RawMessage]()
+
+	run := func(ctx context.Context, batch []json.RawMessage) (out []json.RawMessage, err error) {
+		data, err := codec.Marshal(batch)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err = r.Execute(ctx, name, data)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := codec.Unmarshal(data, &out); err != nil {
+			return nil, err
+		}
+
+		return out, nil
+	}
+
+	if len(values) == 0 {
+		return run(ctx, values)
+	}
+
+	return pipe.Parallel(ctx, pipe.Pipeline[[]json.RawMessage]{run}, values, min(jobs, len(values)), pipe.FailFast())
+}
+
+func read(r io.Reader, format string) ([]json.RawMessage, error) {
+	switch format {
+	case FormatJSON:
+		return readJSON(r)
+	case FormatLines:
+		return readLines(r)
+	case FormatCSV:
+		return readCSV(r)
+	default:
+		return nil, fmt.Errorf("pipe: unknown input format %q", format)
+	}
+}
+
+func readJSON(r io.Reader) (values []json.RawMessage, err error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	for {
+		var v json.RawMessage
+
+		err := dec.Decode(&v)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, v)
+	}
+
+	// A single array is the list of values.
+	if len(values) == 1 && len(values[0]) > 0 && values[0][0] == '[' {
+		var arr []json.RawMessage
+
+		if err := json.Unmarshal(values[0], &arr); err != nil {
+			return nil, err
+		}
+
+		return arr, nil
+	}
+
+	return values, nil
+}
+
+func readLines(r io.Reader) (values []json.RawMessage, err error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+
+	for sc.Scan() {
+		v, err := json.Marshal(sc.Text())
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, v)
+	}
+
+	return values, sc.Err()
+}
+
+func readCSV(r io.Reader) (values []json.RawMessage, err error) {
+	cr := csv.NewReader(r)
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return values, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		obj := make(map[string]string, len(header))
+
+		for i, key := range header {
+			obj[key] = rec[i]
+		}
+
+		v, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, v)
+	}
+}
+
+func write(w io.Writer, values []json.RawMessage, format string) error {
+	bw := bufio.NewWriter(w)
+
+	if format == OutputJSONLines {
+		for _, v := range values {
+			bw.Write(v)
+			bw.WriteByte('\n')
+		}
+
+		return bw.Flush()
+	}
+
+	if values == nil {
+		values = []json.RawMessage{}
+	}
+
+	enc := json.NewEncoder(bw)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(values); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
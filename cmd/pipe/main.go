@@ -0,0 +1,19 @@
+// Command pipe runs pipelines registered in pipe.DefaultRegistry against JSON, text lines or CSV input.
+//
+// Pipelines are linked into the command by blank imports of packages registering them from init functions,
+// add such imports to a file of this directory, e.g. pipelines.go, and build the command:
+//
+//	pipe -list
+//	pipe -format csv -in orders.csv -jobs 4 -timeout 1m -out jsonl enrich
+//
+// Values are exchanged with pipelines as JSON, see package cli.
+package main
+
+import (
+	"github.com/WinPooh32/pipe"
+	"github.com/WinPooh32/pipe/cli"
+)
+
+func main() {
+	cli.Main(pipe.DefaultRegistry)
+}
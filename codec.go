@@ -0,0 +1,70 @@
+package pipe
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec serializes values of T.
+type Codec[T any] interface {
+	Marshal(v T) ([]byte, error)
+	Unmarshal(data []byte, v *T) error
+}
+
+// JSONCodec returns codec serializing values with encoding/json.
+func JSONCodec[T any]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+// GobCodec returns codec serializing values with encoding/gob, every value is encoded with its type description.
+func GobCodec[T any]() Codec[T] {
+	return gobCodec[T]{}
+}
+
+// CodecFunc returns codec made of marshal and unmarshal functions. It adapts other formats, e.g. protobuf:
+//
+//	CodecFunc(func(m *pb.Msg) ([]byte, error) { return proto.Marshal(m) },
+//		func(data []byte, m **pb.Msg) error { *m = &pb.Msg{}; return proto.Unmarshal(data, *m) })
+func CodecFunc[T any](marshal func(v T) ([]byte, error), unmarshal func(data []byte, v *T) error) Codec[T] {
+	return funcCodec[T]{marshal: marshal, unmarshal: unmarshal}
+}
+
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) Marshal(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec[T]) Unmarshal(data []byte, v *T) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec[T any] struct{}
+
+func (gobCodec[T]) Marshal(v T) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gobCodec[T]) Unmarshal(data []byte, v *T) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type funcCodec[T any] struct {
+	marshal   func(v T) ([]byte, error)
+	unmarshal func(data []byte, v *T) error
+}
+
+func (c funcCodec[T]) Marshal(v T) ([]byte, error) {
+	return c.marshal(v)
+}
+
+func (c funcCodec[T]) Unmarshal(data []byte, v *T) error {
+	return c.unmarshal(data, v)
+}
@@ -0,0 +1,175 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors of Compile.
+var (
+	ErrNilHandler    = errors.New("pipeline: nil handler")
+	ErrDuplicateName = errors.New("pipeline: duplicate stage name")
+	ErrConflict      = errors.New("pipeline: conflicting middleware")
+)
+
+// Compiled is a validated immutable pipeline prepared for execution.
+type Compiled[T any] struct {
+	// source holds stages as given to Compile, pipeline holds the unwrapped and instrumented ones.
+	source   Pipeline[T]
+	pipeline Pipeline[T]
+	stages   []StageInfo
+	labels   []map[string]string
+	stats    *pipelineStats
+}
+
+// Compile validates pipeline and returns its executable form.
+// It fails when a stage is nil, names given by Named repeat or the same middleware wraps a stage twice in a row.
+// Errors of stages are joined, every one is *StageError.
+// Compiled pipeline resolves introspection info and labels once and calls stages without pass-through wrappers
+// of Named and Annotate.
+func Compile[T any](pipeline Pipeline[T]) (*Compiled[T], error) {
+	c := &Compiled[T]{
+		source:   append(Pipeline[T](nil), pipeline...),
+		pipeline: make(Pipeline[T], len(pipeline)),
+		stages:   make([]StageInfo, len(pipeline)),
+		labels:   make([]map[string]string, len(pipeline)),
+	}
+
+	var errs []error
+
+	names := map[string]int{}
+
+	for i, handler := range pipeline {
+		if handler == nil {
+			errs = append(errs, &StageError{Stage: i, Err: ErrNilHandler})
+			continue
+		}
+
+		info := describeHandler(handler)
+		info.Index = i
+
+		if named(handler) {
+			if j, ok := names[info.Name]; ok {
+				errs = append(errs, &StageError{Stage: i, Err: fmt.Errorf("%w: %q is used by stage %d", ErrDuplicateName, info.Name, j)})
+			} else {
+				names[info.Name] = i
+			}
+		}
+
+		for j := 1; j < len(info.Middleware); j++ {
+			if kind(info.Middleware[j]) == kind(info.Middleware[j-1]) {
+				errs = append(errs, &StageError{Stage: i, Err: fmt.Errorf("%w: %s > %s", ErrConflict, info.Middleware[j-1], info.Middleware[j])})
+			}
+		}
+
+		c.pipeline[i] = unwrap(handler)
+		c.stages[i] = info
+		c.labels[i] = info.Labels
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	c.stats, c.pipeline = instrument(c.pipeline)
+
+	return c, nil
+}
+
+// Clone returns copy of the compiled pipeline with own stats and stages cloned as by Pipeline.Clone.
+func (c *Compiled[T]) Clone() *Compiled[T] {
+	clone := &Compiled[T]{
+		source: c.source.Clone(),
+		stages: c.stages,
+		labels: c.labels,
+	}
+
+	p := make(Pipeline[T], len(clone.source))
+
+	for i, handler := range clone.source {
+		p[i] = unwrap(handler)
+	}
+
+	clone.stats, clone.pipeline = instrument(p)
+
+	return clone
+}
+
+// Execute executes the compiled pipeline.
+func (c *Compiled[T]) Execute(ctx context.Context, in T) (out T, err error) {
+	out, err = execute(ctx, c.pipeline, c.labels, in, nil)
+	c.stats.observe(err)
+
+	return out, err
+}
+
+// Stats returns snapshot of counters of runs and stages of the compiled pipeline, it is safe for concurrent use.
+func (c *Compiled[T]) Stats() PipelineStats {
+	return c.stats.snapshot()
+}
+
+// Handler returns handler executing the compiled pipeline.
+func (c *Compiled[T]) Handler() HandlerFunc[T] {
+	clone := func() any { return c.Clone().Handler() }
+
+	return describe(c.Execute, describeInfo{middleware: "Compiled", inner: c, clone: clone})
+}
+
+// Stages describes stages of the compiled pipeline.
+func (c *Compiled[T]) Stages() []StageInfo {
+	return append([]StageInfo(nil), c.stages...)
+}
+
+func (c *Compiled[T]) String() string {
+	return formatStages(c.stages)
+}
+
+// named reports whether the stage has a name given by Named.
+func named(handler any) bool {
+	for depth := 0; depth < maxDiffDepth; depth++ {
+		d, ok := query(handler)
+		if !ok {
+			return false
+		}
+
+		if d.info.name != "" {
+			return true
+		}
+
+		handler = d.info.inner
+	}
+
+	return false
+}
+
+// unwrap strips wrappers of describe from the handler while they do nothing but pass calls through.
+func unwrap[T any](handler HandlerFunc[T]) HandlerFunc[T] {
+	for {
+		d, ok := query(handler)
+		if !ok {
+			return handler
+		}
+
+		h, ok := d.handler.(HandlerFunc[T])
+		if !ok {
+			return handler
+		}
+
+		handler = h
+
+		if d.info.middleware != "" {
+			return handler
+		}
+	}
+}
+
+// kind returns name of middleware without its options.
+func kind(middleware string) string {
+	if i := strings.IndexByte(middleware, '('); i >= 0 {
+		return middleware[:i]
+	}
+
+	return middleware
+}
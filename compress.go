@@ -0,0 +1,182 @@
+package pipe
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxDecompressed is the maximum size of a decompressed value when no MaxDecompressed option is given.
+const DefaultMaxDecompressed = 64 << 20
+
+// ErrDecompressedSize is returned when a decompressed value exceeds its maximum size.
+var ErrDecompressedSize = errors.New("pipeline: decompressed value is too large")
+
+// DecompressOption configures Decompress and DecompressSource.
+type DecompressOption func(*decompressConfig)
+
+type decompressConfig struct {
+	max int64
+}
+
+// MaxDecompressed sets the maximum size of a decompressed value, it guards against inputs of small size
+// expanding to exhaust memory. Larger values fail with ErrDecompressedSize.
+func MaxDecompressed(n int64) DecompressOption {
+	if n <= 0 {
+		panic("max decompressed value must be greater than zero!")
+	}
+
+	return func(c *decompressConfig) {
+		c.max = n
+	}
+}
+
+func newDecompressConfig(opts []DecompressOption) decompressConfig {
+	cfg := decompressConfig{max: DefaultMaxDecompressed}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return cfg
+}
+
+// Compressor is a compression format, e.g. gzip or zstd.
+type Compressor interface {
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// Gzip is gzip Compressor with the default compression level.
+var Gzip Compressor = GzipLevel(gzip.DefaultCompression)
+
+// GzipLevel returns gzip Compressor with the compression level, see compress/gzip.
+func GzipLevel(level int) Compressor {
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		panic("level value must be a valid gzip compression level!")
+	}
+
+	return gzipCompressor{level: level}
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+func (c gzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, c.level)
+}
+
+func (c gzipCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// Compress returns handler compressing its input with c.
+func Compress(c Compressor) HandlerFunc[[]byte] {
+	fn := func(ctx context.Context, in []byte) (out []byte, err error) {
+		return compress(c, in)
+	}
+
+	return fn
+}
+
+// Decompress returns handler decompressing its input with c.
+// Values are limited to DefaultMaxDecompressed bytes unless MaxDecompressed is given.
+func Decompress(c Compressor, opts ...DecompressOption) HandlerFunc[[]byte] {
+	cfg := newDecompressConfig(opts)
+
+	fn := func(ctx context.Context, in []byte) (out []byte, err error) {
+		return decompress(c, in, cfg.max)
+	}
+
+	return fn
+}
+
+// CompressSink returns sink passing values compressed with c to sink.
+func CompressSink(c Compressor, sink Sink[[]byte]) Sink[[]byte] {
+	fn := func(ctx context.Context, in []byte) error {
+		data, err := compress(c, in)
+		if err != nil {
+			return err
+		}
+
+		return sink(ctx, data)
+	}
+
+	return fn
+}
+
+// DecompressSource returns source emitting values of src decompressed with c, limited like by Decompress.
+func DecompressSource(c Compressor, src Source[[]byte], opts ...DecompressOption) Source[[]byte] {
+	cfg := newDecompressConfig(opts)
+
+	fn := func(ctx context.Context, out chan<- []byte) error {
+		return forward(ctx, src, func(ctx context.Context, values <-chan []byte) error {
+			for {
+				select {
+				case <-ctx.Done():
+					return cause(ctx)
+				case v, ok := <-values:
+					if !ok {
+						return nil
+					}
+
+					data, err := decompress(c, v, cfg.max)
+					if err != nil {
+						return err
+					}
+
+					select {
+					case <-ctx.Done():
+						return cause(ctx)
+					case out <- data:
+					}
+				}
+			}
+		})
+	}
+
+	return fn
+}
+
+func compress(c Compressor, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w, err := c.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func decompress(c Compressor, data []byte, limit int64) ([]byte, error) {
+	r, err := c.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	out, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(out)) > limit {
+		return nil, fmt.Errorf("%w: over %d bytes", ErrDecompressedSize, limit)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,61 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestDecompress(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 1024)
+
+	compressed, err := Execute(context.Background(), Pipeline[[]byte]{Compress(Gzip)}, slices.Clone(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts []DecompressOption
+		err  error
+	}{
+		{name: "default"},
+		{name: "exact limit", opts: []DecompressOption{MaxDecompressed(1024)}},
+		{name: "over limit", opts: []DecompressOption{MaxDecompressed(1023)}, err: ErrDecompressedSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Execute(context.Background(), Pipeline[[]byte]{Decompress(Gzip, tt.opts...)}, compressed)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			if tt.err == nil && !bytes.Equal(out, data) {
+				t.Fatalf("got %d decompressed bytes, want %d", len(out), len(data))
+			}
+		})
+
+		t.Run(tt.name+"/source", func(t *testing.T) {
+			var out [][]byte
+
+			sink := func(ctx context.Context, in []byte) error {
+				out = append(out, in)
+				return nil
+			}
+
+			src := DecompressSource(Gzip, FromSlice([][]byte{compressed}), tt.opts...)
+
+			err := NewStream[[]byte]().Run(context.Background(), src, sink)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			if tt.err == nil && (len(out) != 1 || !bytes.Equal(out[0], data)) {
+				t.Fatalf("got %d values, want the decompressed input", len(out))
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package pipe
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// CorrelationKey is the metadata key of correlation ID set by Correlate.
+var CorrelationKey = NewMetaKey[string]("correlation_id")
+
+// Correlate returns stage assigning correlation ID to the run or stream element: the ID extracted from
+// the value or a random one when extract is nil or returns empty string. The ID is kept in metadata,
+// so it is reported with hook events and StageError. Values are passed further unchanged.
+func Correlate[T any](extract func(T) string) HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		var id string
+
+		if extract != nil {
+			id = extract(in)
+		}
+
+		if id == "" {
+			id = newCorrelationID()
+		}
+
+		CorrelationKey.Set(ctx, id)
+
+		return in, nil
+	}
+
+	return fn
+}
+
+// CorrelationID returns correlation ID of the run or stream element, empty if it is not assigned.
+func CorrelationID(ctx context.Context) string {
+	id, _ := CorrelationKey.Get(ctx)
+	return id
+}
+
+func newCorrelationID() string {
+	var b [16]byte
+
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,146 @@
+package pipe
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrUnknownKey is returned by KeyProvider when there is no key with the requested ID.
+	ErrUnknownKey = errors.New("pipeline: unknown key")
+	// ErrCiphertext is returned by Decrypt when the payload is not a ciphertext produced by Encrypt.
+	ErrCiphertext = errors.New("pipeline: malformed ciphertext")
+)
+
+// KeyProvider supplies keys of Encrypt and Decrypt. Rotating the current key keeps payloads
+// encrypted with the previous keys decryptable as long as Key returns them.
+type KeyProvider interface {
+	// Current returns ID and key for encrypting new payloads.
+	Current(ctx context.Context) (id string, key []byte, err error)
+	// Key returns key with the ID.
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// StaticKeys is KeyProvider holding keys in memory.
+type StaticKeys struct {
+	// CurrentID is the ID of the key for encryption.
+	CurrentID string
+	Keys      map[string][]byte
+}
+
+func (k StaticKeys) Current(ctx context.Context) (id string, key []byte, err error) {
+	key, err = k.Key(ctx, k.CurrentID)
+	return k.CurrentID, key, err
+}
+
+func (k StaticKeys) Key(ctx context.Context, id string) ([]byte, error) {
+	key, ok := k.Keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+// Cipher is an authenticated encryption scheme, additionalData is authenticated but not encrypted.
+type Cipher interface {
+	Seal(key, plaintext, additionalData []byte) ([]byte, error)
+	Open(key, ciphertext, additionalData []byte) ([]byte, error)
+}
+
+// AESGCM is Cipher using AES in GCM mode with random nonces, keys must be 16, 24 or 32 bytes long.
+var AESGCM Cipher = aesGCM{}
+
+type aesGCM struct{}
+
+func (aesGCM) aead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (c aesGCM) Seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := c.aead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func (c aesGCM) Open(key, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := c.aead(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrCiphertext
+	}
+
+	nonce, data := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, data, additionalData)
+}
+
+// Encrypt returns handler encrypting its input with the current key of keys.
+// The key ID is stored in front of the ciphertext, so Decrypt finds the key after rotation,
+// and is authenticated with it, so a payload relabeled with another key ID fails to decrypt.
+func Encrypt(c Cipher, keys KeyProvider) HandlerFunc[[]byte] {
+	fn := func(ctx context.Context, in []byte) (out []byte, err error) {
+		id, key, err := keys.Current(ctx)
+		if err != nil {
+			return out, err
+		}
+
+		if len(id) > 255 {
+			return out, errors.New("pipeline: key id is longer than 255 bytes")
+		}
+
+		header := make([]byte, 0, 1+len(id))
+		header = append(header, byte(len(id)))
+		header = append(header, id...)
+
+		sealed, err := c.Seal(key, in, header)
+		if err != nil {
+			return out, err
+		}
+
+		return append(header, sealed...), nil
+	}
+
+	return fn
+}
+
+// Decrypt returns handler decrypting payloads produced by Encrypt.
+func Decrypt(c Cipher, keys KeyProvider) HandlerFunc[[]byte] {
+	fn := func(ctx context.Context, in []byte) (out []byte, err error) {
+		if len(in) == 0 || len(in) < 1+int(in[0]) {
+			return out, ErrCiphertext
+		}
+
+		header := in[:1+int(in[0])]
+
+		key, err := keys.Key(ctx, string(header[1:]))
+		if err != nil {
+			return out, err
+		}
+
+		return c.Open(key, in[len(header):], header)
+	}
+
+	return fn
+}
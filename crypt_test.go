@@ -0,0 +1,70 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEncrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	// Both IDs map to the same key, so only authentication of the header tells them apart.
+	keys := StaticKeys{CurrentID: "a", Keys: map[string][]byte{"a": key, "b": key, "old": bytes.Repeat([]byte{2}, 16)}}
+
+	plaintext := []byte("payload")
+
+	sealed, err := Encrypt(AESGCM, keys)(context.Background(), plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := Encrypt(AESGCM, StaticKeys{CurrentID: "old", Keys: keys.Keys})(context.Background(), plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relabeled := append([]byte{1, 'b'}, sealed[2:]...)
+
+	tampered := bytes.Clone(sealed)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name    string
+		in      []byte
+		keys    KeyProvider
+		wantErr error
+		fails   bool
+	}{
+		{name: "round trip", in: sealed, keys: keys},
+		{name: "rotated", in: old, keys: keys},
+		{name: "relabeled", in: relabeled, keys: keys, fails: true},
+		{name: "tampered", in: tampered, keys: keys, fails: true},
+		{name: "unknown key", in: sealed, keys: StaticKeys{Keys: map[string][]byte{"b": key}}, wantErr: ErrUnknownKey},
+		{name: "empty", in: nil, keys: keys, wantErr: ErrCiphertext},
+		{name: "short header", in: []byte{5, 'a'}, keys: keys, wantErr: ErrCiphertext},
+		{name: "short ciphertext", in: []byte{1, 'a', 0}, keys: keys, wantErr: ErrCiphertext},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := Decrypt(AESGCM, tt.keys)(context.Background(), tt.in)
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+			case tt.fails:
+				if err == nil {
+					t.Fatalf("got %q, want error", out)
+				}
+			default:
+				if err != nil || !bytes.Equal(out, plaintext) {
+					t.Fatalf("got %q, %v, want %q, nil", out, err, plaintext)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DeadlineKey is the metadata key of the time after which the value is not worth processing, see DropExpired.
+var DeadlineKey = NewMetaKey[time.Time]("deadline")
+
+// ExpiredKey is the metadata key set to true for expired values processed by ExpireFlag policy.
+var ExpiredKey = NewMetaKey[bool]("expired")
+
+// ErrExpired is returned by stages of DropExpired for values which deadline has passed, it wraps ErrDropped.
+var ErrExpired = fmt.Errorf("%w: deadline has passed", ErrDropped)
+
+// TTL returns stage setting deadline of the value to ttl from now, an earlier deadline set before is kept.
+// Put it first, so the deadline counts from the moment the value has entered the pipeline.
+func TTL[T any](ttl time.Duration) HandlerFunc[T] {
+	if ttl <= 0 {
+		panic("ttl value must be greater than zero!")
+	}
+
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		deadline := time.Now().Add(ttl)
+
+		if d, ok := DeadlineKey.Get(ctx); !ok || deadline.Before(d) {
+			DeadlineKey.Set(ctx, deadline)
+		}
+
+		return in, nil
+	}
+
+	return fn
+}
+
+// ExpiryPolicy handles expired value instead of handler.
+type ExpiryPolicy[T any] func(ctx context.Context, in T, handler HandlerFunc[T]) (out T, err error)
+
+// ExpireDrop returns policy dropping expired values with ErrExpired.
+func ExpireDrop[T any]() ExpiryPolicy[T] {
+	fn := func(ctx context.Context, in T, handler HandlerFunc[T]) (out T, err error) {
+		emit(ctx, Event{Kind: EventDropped, In: in, Err: ErrExpired})
+		return in, ErrExpired
+	}
+
+	return fn
+}
+
+// ExpireDeadLetter returns policy passing expired values to deadLetter and dropping them with ErrExpired.
+// Failure of deadLetter is returned instead.
+func ExpireDeadLetter[T any](deadLetter Sink[T]) ExpiryPolicy[T] {
+	fn := func(ctx context.Context, in T, handler HandlerFunc[T]) (out T, err error) {
+		if err := deadLetter(ctx, in); err != nil {
+			return out, err
+		}
+
+		emit(ctx, Event{Kind: EventDropped, In: in, Err: ErrExpired})
+
+		return in, ErrExpired
+	}
+
+	return fn
+}
+
+// ExpireFlag returns policy handling expired values as usual with ExpiredKey metadata set to true,
+// so stages with side effects can check it.
+func ExpireFlag[T any]() ExpiryPolicy[T] {
+	fn := func(ctx context.Context, in T, handler HandlerFunc[T]) (out T, err error) {
+		ExpiredKey.Set(ctx, true)
+		return handler(ctx, in)
+	}
+
+	return fn
+}
+
+// Expire returns handler applying policy to values which deadline has passed instead of calling handler.
+// The deadline is returned by deadline, nil deadline means DeadlineKey metadata. Values without deadline are handled.
+func Expire[T any](handler HandlerFunc[T], deadline func(ctx context.Context, in T) (time.Time, bool), policy ExpiryPolicy[T]) HandlerFunc[T] {
+	clone := func() any { return Expire(cloneHandler(handler), deadline, policy) }
+
+	return describe(expire(handler, deadline, policy), describeInfo{middleware: "Expire", inner: handler, clone: clone})
+}
+
+// DropExpired returns handler dropping values which deadline has passed instead of calling handler,
+// wrap expensive stages with it. It is Expire with ExpireDrop policy.
+// Drops are reported to hooks as EventDropped with ErrExpired.
+func DropExpired[T any](handler HandlerFunc[T], deadline func(ctx context.Context, in T) (time.Time, bool)) HandlerFunc[T] {
+	clone := func() any { return DropExpired(cloneHandler(handler), deadline) }
+
+	return describe(expire(handler, deadline, ExpireDrop[T]()), describeInfo{middleware: "DropExpired", inner: handler, clone: clone})
+}
+
+func expire[T any](handler HandlerFunc[T], deadline func(ctx context.Context, in T) (time.Time, bool), policy ExpiryPolicy[T]) HandlerFunc[T] {
+	if deadline == nil {
+		deadline = func(ctx context.Context, in T) (time.Time, bool) {
+			return DeadlineKey.Get(ctx)
+		}
+	}
+
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		if d, ok := deadline(ctx, in); ok && !time.Now().Before(d) {
+			return policy(ctx, in, handler)
+		}
+
+		return handler(ctx, in)
+	}
+
+	return fn
+}
@@ -0,0 +1,114 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExpire(t *testing.T) {
+	errBroken := errors.New("broken")
+
+	var calls atomic.Int32
+
+	h := func(ctx context.Context, in int) (int, error) {
+		calls.Add(1)
+		return in + 1, nil
+	}
+
+	var dead []int
+
+	deadLetter := func(ctx context.Context, in int) error {
+		dead = append(dead, in)
+		return nil
+	}
+
+	broken := func(ctx context.Context, in int) error { return errBroken }
+
+	// byValue treats odd values as expired.
+	byValue := func(ctx context.Context, in int) (time.Time, bool) {
+		if in%2 == 0 {
+			return time.Time{}, false
+		}
+
+		return time.Now().Add(-time.Second), true
+	}
+
+	past := time.Now().Add(-time.Second)
+
+	tests := []struct {
+		name     string
+		deadline time.Time
+		stages   Pipeline[int]
+		in       int
+		want     int
+		wantErr  error
+		calls    int32
+		dead     int
+		dropped  int
+		expired  bool
+	}{
+		{name: "no deadline", stages: Pipeline[int]{DropExpired(h, nil)}, want: 1, calls: 1},
+		{name: "ttl", stages: Pipeline[int]{TTL[int](time.Hour), DropExpired(h, nil)}, want: 1, calls: 1},
+		{name: "earlier deadline", deadline: past, stages: Pipeline[int]{TTL[int](time.Hour), DropExpired(h, nil)}, wantErr: ErrExpired, dropped: 1},
+		{name: "drop", deadline: past, stages: Pipeline[int]{Expire(h, nil, ExpireDrop[int]())}, wantErr: ErrExpired, dropped: 1},
+		{name: "dead letter", deadline: past, stages: Pipeline[int]{Expire(h, nil, ExpireDeadLetter(deadLetter))}, wantErr: ErrExpired, dead: 1, dropped: 1},
+		{name: "broken dead letter", deadline: past, stages: Pipeline[int]{Expire(h, nil, ExpireDeadLetter(broken))}, wantErr: errBroken},
+		{name: "flag", deadline: past, stages: Pipeline[int]{Expire(h, nil, ExpireFlag[int]())}, want: 1, calls: 1, expired: true},
+		{name: "deadline of value", in: 1, stages: Pipeline[int]{DropExpired(h, byValue)}, wantErr: ErrExpired, dropped: 1},
+		{name: "live value", in: 2, deadline: past, stages: Pipeline[int]{DropExpired(h, byValue)}, want: 3, calls: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			dead = nil
+
+			var dropped atomic.Int32
+
+			hook := func(ctx context.Context, e Event) {
+				if e.Kind == EventDropped && errors.Is(e.Err, ErrExpired) {
+					dropped.Add(1)
+				}
+			}
+
+			ctx, _ := WithMetadata(WithHook(context.Background(), hook))
+
+			if !tt.deadline.IsZero() {
+				DeadlineKey.Set(ctx, tt.deadline)
+			}
+
+			out, err := Execute(ctx, tt.stages, tt.in)
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil || out != tt.want {
+				t.Fatalf("got %d, %v, want %d, nil", out, err, tt.want)
+			}
+
+			if n := calls.Load(); n != tt.calls {
+				t.Fatalf("got %d calls of handler, want %d", n, tt.calls)
+			}
+
+			if len(dead) != tt.dead {
+				t.Fatalf("got dead letters %v, want %d", dead, tt.dead)
+			}
+
+			if n := dropped.Load(); n != int32(tt.dropped) {
+				t.Fatalf("got %d drops, want %d", n, tt.dropped)
+			}
+
+			if expired, _ := ExpiredKey.Get(ctx); expired != tt.expired {
+				t.Fatalf("got expired flag %t, want %t", expired, tt.expired)
+			}
+		})
+	}
+
+	if !errors.Is(ErrExpired, ErrDropped) {
+		t.Fatal("ErrExpired does not wrap ErrDropped")
+	}
+}
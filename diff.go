@@ -0,0 +1,231 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Differ describes changes between the input and the output of a stage,
+// it returns empty string when nothing has changed.
+type Differ[T any] func(in, out T) string
+
+// DiffStages returns pipeline emitting EventDiff with changes done by every stage of pipeline to its value.
+// Event stage is the index of the stage in pipeline.
+//
+// When differ is nil, values are compared field by field using reflection. The input is captured before
+// the stage runs, so in-place modifications are reported too. A custom differ receives the input
+// after the stage has run.
+func DiffStages[T any](pipeline Pipeline[T], differ Differ[T]) Pipeline[T] {
+	wrapped := make(Pipeline[T], len(pipeline))
+
+	for i, handler := range pipeline {
+		wrapped[i] = diffStage(i, handler, differ)
+	}
+
+	return wrapped
+}
+
+func diffStage[T any](stage int, handler HandlerFunc[T], differ Differ[T]) HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		hook := hookFrom(ctx)
+		if hook == nil {
+			return handler(ctx, in)
+		}
+
+		var before []field
+
+		if differ == nil {
+			before = flatten(in)
+		}
+
+		out, err = handler(ctx, in)
+		if err != nil {
+			return out, err
+		}
+
+		var diff string
+
+		if differ == nil {
+			diff = diffFields(before, flatten(out))
+		} else {
+			diff = differ(in, out)
+		}
+
+		if diff != "" {
+			emitTo(ctx, hook, stage, Event{Kind: EventDiff, In: in, Out: out, Diff: diff})
+		}
+
+		return out, nil
+	}
+
+	clone := func() any { return diffStage(stage, cloneHandler(handler), differ) }
+
+	return describe(fn, describeInfo{middleware: "Diff", inner: handler, clone: clone})
+}
+
+// field is a leaf value of a flattened value.
+type field struct {
+	path  string
+	value string
+}
+
+// maxDiffDepth limits nesting of flattened values.
+const maxDiffDepth = 32
+
+func flatten(v any) []field {
+	var fields []field
+
+	flattenValue(&fields, "", reflect.ValueOf(v), map[uintptr]bool{}, 0)
+
+	return fields
+}
+
+func flattenValue(fields *[]field, path string, v reflect.Value, seen map[uintptr]bool, depth int) {
+	leaf := func(value string) {
+		if path == "" {
+			path = "."
+		}
+
+		*fields = append(*fields, field{path: path, value: value})
+	}
+
+	if !v.IsValid() {
+		leaf("<nil>")
+		return
+	}
+
+	if depth > maxDiffDepth {
+		leaf("<too deep>")
+		return
+	}
+
+	if v.CanInterface() {
+		if s, ok := v.Interface().(fmt.Stringer); ok && v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface {
+			leaf(s.String())
+			return
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			leaf("nil")
+			return
+		}
+
+		if v.Kind() == reflect.Pointer {
+			if seen[v.Pointer()] {
+				leaf("<cycle>")
+				return
+			}
+
+			seen[v.Pointer()] = true
+			defer delete(seen, v.Pointer())
+		}
+
+		flattenValue(fields, path, v.Elem(), seen, depth+1)
+
+	case reflect.Struct:
+		t := v.Type()
+
+		if t.NumField() == 0 {
+			leaf("{}")
+			return
+		}
+
+		for i := 0; i < t.NumField(); i++ {
+			flattenValue(fields, path+"."+t.Field(i).Name, v.Field(i), seen, depth+1)
+		}
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			leaf("nil")
+			return
+		}
+
+		if v.Len() == 0 {
+			leaf("[]")
+			return
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			flattenValue(fields, fmt.Sprintf("%s[%d]", path, i), v.Index(i), seen, depth+1)
+		}
+
+	case reflect.Map:
+		if v.IsNil() {
+			leaf("nil")
+			return
+		}
+
+		if v.Len() == 0 {
+			leaf("map[]")
+			return
+		}
+
+		keys := v.MapKeys()
+		names := make([]string, len(keys))
+
+		for i, k := range keys {
+			names[i] = fmt.Sprint(k)
+		}
+
+		sort.Sort(byName{keys, names})
+
+		for i, k := range keys {
+			flattenValue(fields, fmt.Sprintf("%s[%s]", path, names[i]), v.MapIndex(k), seen, depth+1)
+		}
+
+	default:
+		leaf(fmt.Sprintf("%#v", v))
+	}
+}
+
+type byName struct {
+	keys  []reflect.Value
+	names []string
+}
+
+func (b byName) Len() int           { return len(b.keys) }
+func (b byName) Less(i, j int) bool { return b.names[i] < b.names[j] }
+func (b byName) Swap(i, j int) {
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+	b.names[i], b.names[j] = b.names[j], b.names[i]
+}
+
+// diffFields returns lines describing changed, removed and added fields.
+func diffFields(before, after []field) string {
+	values := make(map[string]string, len(after))
+
+	for _, f := range after {
+		values[f.path] = f.value
+	}
+
+	var b strings.Builder
+
+	known := make(map[string]bool, len(before))
+
+	for _, f := range before {
+		known[f.path] = true
+
+		value, ok := values[f.path]
+
+		switch {
+		case !ok:
+			fmt.Fprintf(&b, "- %s: %s\n", f.path, f.value)
+		case value != f.value:
+			fmt.Fprintf(&b, "~ %s: %s -> %s\n", f.path, f.value, value)
+		}
+	}
+
+	for _, f := range after {
+		if !known[f.path] {
+			fmt.Fprintf(&b, "+ %s: %s\n", f.path, f.value)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,249 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxBatchBytes is the maximum size of a serialized batch read by NodeHandler and HTTPNode by default.
+const DefaultMaxBatchBytes = 32 << 20
+
+// Node executes pipelines registered by name over serialized batches, e.g. a remote worker process.
+// Registry is a Node executing pipelines locally.
+type Node interface {
+	Execute(ctx context.Context, pipeline string, batch []byte) ([]byte, error)
+}
+
+// NodeError is the error of a batch executed by a node.
+type NodeError struct {
+	// Node is the index of the node.
+	Node int
+	Err  error
+}
+
+func (e *NodeError) Error() string {
+	return fmt.Sprintf("pipeline: node %d: %s", e.Node, e.Err)
+}
+
+func (e *NodeError) Unwrap() error {
+	return e.Err
+}
+
+// Distribute splits 'in' between nodes and executes the pipeline registered by name on every node
+// with its own batch. Order of results will be same as input.
+// When some of nodes fail, out holds results of the succeeded batches
+// and err is *PartialError which failed batches have *NodeError.
+func Distribute[T any](ctx context.Context, nodes []Node, name string, codec Codec[[]T], in []T) (out []T, err error) {
+	if len(nodes) == 0 {
+		panic("nodes must not be empty!")
+	}
+
+	batches := split[T](len(in), len(nodes))
+
+	var wg sync.WaitGroup
+
+	for i := range batches {
+		b := &batches[i]
+
+		wg.Add(1)
+		spawn(ctx, func() {
+			defer wg.Done()
+
+			b.Out, b.Err = execNode(ctx, nodes[b.Index], name, codec, in[b.Offset:b.Offset+b.Len])
+			if b.Err != nil {
+				b.Err = &NodeError{Node: b.Index, Err: b.Err}
+			}
+
+			b.State = batchState(ctx, b.Err)
+		})
+	}
+
+	wg.Wait()
+
+	return gather(batches, nil, false)
+}
+
+func execNode[T any](ctx context.Context, node Node, name string, codec Codec[[]T], in []T) (out []T, err error) {
+	data, err := codec.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = node.Execute(ctx, name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := codec.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// RemoteError is the error reported by NodeHandler.
+type RemoteError struct {
+	Status  int
+	Message string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("pipeline: remote: %d: %s", e.Status, e.Message)
+}
+
+// Unwrap returns ErrUnknownPipeline when the remote registry has no requested pipeline.
+func (e *RemoteError) Unwrap() error {
+	if e.Status == http.StatusNotFound {
+		return ErrUnknownPipeline
+	}
+
+	return nil
+}
+
+// HTTPNode is a Node calling NodeHandler served at URL.
+type HTTPNode struct {
+	URL string
+	// Client is used for requests, http.DefaultClient if nil.
+	Client *http.Client
+	// MaxResponseBytes limits the size of results, DefaultMaxBatchBytes if zero.
+	MaxResponseBytes int64
+}
+
+func (n HTTPNode) Execute(ctx context.Context, pipeline string, batch []byte) ([]byte, error) {
+	url := strings.TrimSuffix(n.URL, "/") + "/" + pipeline
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(batch))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &RemoteError{Status: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+
+	limit := n.MaxResponseBytes
+	if limit <= 0 {
+		limit = DefaultMaxBatchBytes
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("pipeline: remote: result exceeds %d bytes", limit)
+	}
+
+	return body, nil
+}
+
+// NodeOption configures NodeHandler.
+type NodeOption func(*nodeConfig)
+
+type nodeConfig struct {
+	maxBody int64
+	logger  *slog.Logger
+}
+
+// NodeMaxBody limits the size of request bodies, it is DefaultMaxBatchBytes by default.
+// Larger requests are rejected with status 413.
+func NodeMaxBody(n int64) NodeOption {
+	if n <= 0 {
+		panic("max body value must be greater than zero!")
+	}
+
+	return func(c *nodeConfig) {
+		c.maxBody = n
+	}
+}
+
+// NodeLogger sets logger of failed requests, it is slog.Default() by default.
+func NodeLogger(logger *slog.Logger) NodeOption {
+	return func(c *nodeConfig) {
+		c.logger = logger
+	}
+}
+
+// NodeHandler returns handler serving pipelines of registry for HTTPNode,
+// the pipeline name is the last element of the request path.
+// Errors are logged, clients receive only their status, so details of failures do not leak to them.
+func NodeHandler(registry *Registry, opts ...NodeOption) http.Handler {
+	cfg := nodeConfig{maxBody: DefaultMaxBatchBytes}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.logger == nil {
+		cfg.logger = slog.Default()
+	}
+
+	fail := func(w http.ResponseWriter, r *http.Request, name string, status int, err error) {
+		cfg.logger.LogAttrs(r.Context(), slog.LevelError, "pipeline: node request has failed",
+			slog.String("pipeline", name), slog.Int("status", status), slog.String("error", err.Error()))
+
+		http.Error(w, http.StatusText(status), status)
+	}
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+		batch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cfg.maxBody))
+		if err != nil {
+			status := http.StatusBadRequest
+
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) {
+				status = http.StatusRequestEntityTooLarge
+			}
+
+			fail(w, r, name, status, err)
+
+			return
+		}
+
+		out, err := registry.Execute(r.Context(), name, batch)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrUnknownPipeline) {
+				status = http.StatusNotFound
+			}
+
+			fail(w, r, name, status, err)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(out)
+	}
+
+	return http.HandlerFunc(fn)
+}
@@ -0,0 +1,80 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNodeHandler(t *testing.T) {
+	registry := &Registry{}
+
+	Register(registry, "echo", JSONCodec[[]int](), Pipeline[[]int]{})
+	Register(registry, "broken", JSONCodec[[]int](), Pipeline[[]int]{func(ctx context.Context, in []int) ([]int, error) {
+		return nil, errors.New("secret details")
+	}})
+
+	var log bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&log, nil))
+
+	srv := httptest.NewServer(NodeHandler(registry, NodeMaxBody(64), NodeLogger(logger)))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		pipeline string
+		batch    []byte
+		limit    int64
+		want     string
+		status   int
+		err      error
+	}{
+		{name: "success", pipeline: "echo", batch: []byte("[1,2]"), want: "[1,2]"},
+		{name: "unknown", pipeline: "missing", batch: []byte("[1]"), status: http.StatusNotFound, err: ErrUnknownPipeline},
+		{name: "failure", pipeline: "broken", batch: []byte("[1]"), status: http.StatusInternalServerError},
+		{name: "large request", pipeline: "echo", batch: []byte("[" + strings.Repeat("1,", 64) + "1]"), status: http.StatusRequestEntityTooLarge},
+		{name: "large response", pipeline: "echo", batch: []byte("[1,2]"), limit: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := HTTPNode{URL: srv.URL, MaxResponseBytes: tt.limit}
+
+			out, err := node.Execute(context.Background(), tt.pipeline, tt.batch)
+
+			switch {
+			case tt.want != "":
+				if err != nil || string(out) != tt.want {
+					t.Fatalf("got %s, %v, want %s, nil", out, err, tt.want)
+				}
+			case tt.status != 0:
+				var re *RemoteError
+				if !errors.As(err, &re) || re.Status != tt.status {
+					t.Fatalf("got error %v, want status %d", err, tt.status)
+				}
+
+				if re.Message != http.StatusText(tt.status) {
+					t.Fatalf("got message %q, want %q", re.Message, http.StatusText(tt.status))
+				}
+
+				if tt.err != nil && !errors.Is(err, tt.err) {
+					t.Fatalf("got error %v, want %v", err, tt.err)
+				}
+			default:
+				if err == nil {
+					t.Fatalf("got %s, want error", out)
+				}
+			}
+		})
+	}
+
+	if !strings.Contains(log.String(), "secret details") {
+		t.Fatalf("the failure has not been logged:\n%s", log.String())
+	}
+}
@@ -0,0 +1,160 @@
+package pipe
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/gob"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Encoder writes values of T to the writer it has been created for.
+// Encoders buffering data themselves should implement Flush() error.
+type Encoder[T any] interface {
+	Encode(v T) error
+}
+
+// EncoderFunc is a function implementing Encoder.
+type EncoderFunc[T any] func(v T) error
+
+func (f EncoderFunc[T]) Encode(v T) error {
+	return f(v)
+}
+
+// JSONLines returns encoder writing values as JSON objects separated by newlines.
+func JSONLines[T any](w io.Writer) Encoder[T] {
+	enc := json.NewEncoder(w)
+
+	return EncoderFunc[T](func(v T) error {
+		return enc.Encode(v)
+	})
+}
+
+// GobEncoder returns encoder writing values as a gob stream.
+func GobEncoder[T any](w io.Writer) Encoder[T] {
+	enc := gob.NewEncoder(w)
+
+	return EncoderFunc[T](func(v T) error {
+		return enc.Encode(v)
+	})
+}
+
+// CSVEncoder returns encoder writing records as CSV rows.
+func CSVEncoder(w io.Writer) Encoder[[]string] {
+	return csvEncoder{csv.NewWriter(w)}
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e csvEncoder) Encode(record []string) error {
+	return e.w.Write(record)
+}
+
+func (e csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// EncoderOption configures EncoderSink.
+type EncoderOption func(*encoderConfig)
+
+type encoderConfig struct {
+	buffer     int
+	flushEvery int
+}
+
+// EncoderBuffer sets size of the buffer in front of the writer.
+func EncoderBuffer(size int) EncoderOption {
+	if size <= 0 {
+		panic("size value must be greater than zero!")
+	}
+
+	return func(c *encoderConfig) {
+		c.buffer = size
+	}
+}
+
+// FlushEvery makes the sink flush its buffer after every n values.
+func FlushEvery(n int) EncoderOption {
+	if n <= 0 {
+		panic("n value must be greater than zero!")
+	}
+
+	return func(c *encoderConfig) {
+		c.flushEvery = n
+	}
+}
+
+// EncoderSink serializes values to a writer, its Write method is a Sink.
+// The first error is sticky: it is returned by every following Write and Flush.
+type EncoderSink[T any] struct {
+	mu      sync.Mutex
+	bw      *bufio.Writer
+	enc     Encoder[T]
+	cfg     encoderConfig
+	written int
+	err     error
+}
+
+// NewEncoderSink returns sink writing values to w with encoder created by newEncoder.
+func NewEncoderSink[T any](w io.Writer, newEncoder func(w io.Writer) Encoder[T], opts ...EncoderOption) *EncoderSink[T] {
+	cfg := encoderConfig{buffer: 64 << 10}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	bw := bufio.NewWriterSize(w, cfg.buffer)
+
+	return &EncoderSink[T]{bw: bw, enc: newEncoder(bw), cfg: cfg}
+}
+
+// Write encodes in, it is safe for concurrent use.
+func (s *EncoderSink[T]) Write(ctx context.Context, in T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	if s.err = s.enc.Encode(in); s.err != nil {
+		return s.err
+	}
+
+	s.written++
+
+	if s.cfg.flushEvery > 0 && s.written%s.cfg.flushEvery == 0 {
+		s.err = s.flush()
+	}
+
+	return s.err
+}
+
+// Flush writes buffered data to the writer, call it after the stream has completed.
+func (s *EncoderSink[T]) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.err = s.flush()
+
+	return s.err
+}
+
+func (s *EncoderSink[T]) flush() error {
+	if f, ok := s.enc.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return s.bw.Flush()
+}
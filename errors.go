@@ -0,0 +1,298 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Batch is a part of Parallel input processed by a single job.
+type Batch[T any] struct {
+	// Index is the number of the batch.
+	Index int
+	// Offset is the position of the first element of the batch in the input.
+	Offset int
+	// Len is the number of input elements in the batch.
+	Len int
+	// Out is the batch result, it is not included into Parallel output when Err is not nil.
+	Out []T
+	// Err is the error of the batch pipeline.
+	Err error
+	// State tells how far the batch has got.
+	State BatchState
+}
+
+// BatchState is the outcome of a Parallel batch.
+type BatchState int
+
+const (
+	// BatchNotStarted means the batch was not started because the run had been canceled.
+	BatchNotStarted BatchState = iota
+	// BatchCompleted means the batch has succeeded.
+	BatchCompleted
+	// BatchFailed means the batch has failed on its own.
+	BatchFailed
+	// BatchInterrupted means the batch has failed after the run had been canceled, also by FailFast.
+	BatchInterrupted
+)
+
+func (s BatchState) String() string {
+	switch s {
+	case BatchNotStarted:
+		return "not started"
+	case BatchCompleted:
+		return "completed"
+	case BatchFailed:
+		return "failed"
+	case BatchInterrupted:
+		return "interrupted"
+	default:
+		return "unknown"
+	}
+}
+
+// RunReport is returned by Parallel when its ctx has been done during the run.
+// Out of Parallel holds results of completed batches, the report tells which batches to run again.
+type RunReport[T any] struct {
+	// Err is the cause of ctx.
+	Err error
+	// Batches are all batches of the input in order.
+	Batches []Batch[T]
+}
+
+func (r *RunReport[T]) Error() string {
+	return fmt.Sprintf("pipeline: run canceled: %d completed, %d failed, %d interrupted, %d not started of %d batches: %s",
+		len(r.State(BatchCompleted)), len(r.State(BatchFailed)), len(r.State(BatchInterrupted)),
+		len(r.State(BatchNotStarted)), len(r.Batches), r.Err)
+}
+
+// Unwrap returns the cause of ctx, so errors.Is(err, context.Canceled) holds.
+func (r *RunReport[T]) Unwrap() error {
+	return r.Err
+}
+
+// State returns batches in state.
+func (r *RunReport[T]) State(state BatchState) []Batch[T] {
+	var batches []Batch[T]
+
+	for _, b := range r.Batches {
+		if b.State == state {
+			batches = append(batches, b)
+		}
+	}
+
+	return batches
+}
+
+// PartialError is returned by Parallel when some of batches have failed.
+type PartialError[T any] struct {
+	// Batches are all batches of the input in order.
+	Batches []Batch[T]
+}
+
+func (e *PartialError[T]) Error() string {
+	errs := e.Unwrap()
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "pipeline: %d of %d batches failed", len(errs), len(e.Batches))
+
+	for _, err := range errs {
+		b.WriteString("\n")
+		b.WriteString(err.Error())
+	}
+
+	return b.String()
+}
+
+// Unwrap returns *BatchError for every failed batch, so errors.Is and errors.As inspect all of them.
+func (e *PartialError[T]) Unwrap() []error {
+	var errs []error
+
+	for _, b := range e.Failed() {
+		be := &BatchError{Batch: b.Index, Offset: b.Offset, Len: b.Len, Stage: -1, Err: b.Err}
+
+		var se *StageError
+		if errors.As(b.Err, &se) {
+			be.Stage = se.Stage
+		}
+
+		errs = append(errs, be)
+	}
+
+	return errs
+}
+
+// Completed returns succeeded batches.
+func (e *PartialError[T]) Completed() []Batch[T] {
+	return e.filter(false)
+}
+
+// Failed returns failed batches.
+func (e *PartialError[T]) Failed() []Batch[T] {
+	return e.filter(true)
+}
+
+func (e *PartialError[T]) filter(failed bool) []Batch[T] {
+	var batches []Batch[T]
+
+	for _, b := range e.Batches {
+		if (b.Err != nil) == failed {
+			batches = append(batches, b)
+		}
+	}
+
+	return batches
+}
+
+// PanicError is the error of a recovered panic of a stage or a Parallel job.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value any
+	// Stack is the stack trace of the panicking routine.
+	Stack []byte
+
+	pcs []uintptr
+}
+
+// maxStackDepth limits captured stack traces.
+const maxStackDepth = 32
+
+type stackTracesKey struct{}
+
+// WithStackTraces returns ctx making runs executed with it capture stack traces of failures, see StageError.StackTrace.
+func WithStackTraces(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stackTracesKey{}, true)
+}
+
+func tracing(ctx context.Context) bool {
+	enabled, _ := ctx.Value(stackTracesKey{}).(bool)
+	return enabled
+}
+
+// callers returns program counters of the calling routine skipping skip frames.
+func callers(skip int) []uintptr {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip+1, pcs)
+
+	return pcs[:n]
+}
+
+type noRecoverKey struct{}
+
+// WithoutRecover returns ctx making runs executed with it let panics of stages and Parallel jobs propagate
+// instead of converting them into *PanicError. Use it in crash-only services and for debugging.
+func WithoutRecover(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRecoverKey{}, true)
+}
+
+// recovering reports whether panics are recovered for runs of ctx.
+func recovering(ctx context.Context) bool {
+	disabled, _ := ctx.Value(noRecoverKey{}).(bool)
+	return !disabled
+}
+
+func newPanicError(rec any) *PanicError {
+	return &PanicError{Value: rec, Stack: debug.Stack(), pcs: callers(2)}
+}
+
+// StackTrace returns program counters of the panicking routine.
+func (e *PanicError) StackTrace() []uintptr {
+	return e.pcs
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("pipeline: recovered panic: %v: \n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// BatchError is an error of a failed batch of Parallel.
+type BatchError struct {
+	// Batch is the index of the batch.
+	Batch int
+	// Offset and Len are the range of input elements of the batch.
+	Offset int
+	Len    int
+	// Stage is the index of the failed stage, it is -1 when the batch has failed outside of stages.
+	Stage int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("pipeline: batch %d [%d:%d]: %s", e.Batch, e.Offset, e.Offset+e.Len, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// StageError is returned by Execute and Stream when a stage fails or the run is interrupted before it.
+type StageError struct {
+	// Stage is the index of the stage in the pipeline.
+	Stage int
+	// Err is the error of the stage or the reason of the interruption.
+	Err error
+	// Meta is the snapshot of the run or element metadata at the moment of failure.
+	Meta map[string]any
+	// Labels are annotations of the failed stage, see Annotate.
+	Labels map[string]string
+
+	stack []uintptr
+}
+
+func (e *StageError) Error() string {
+	if id, ok := e.Meta[CorrelationKey.Name()].(string); ok {
+		return fmt.Sprintf("pipeline: stage %d: correlation id %s: %s", e.Stage, id, e.Err)
+	}
+
+	return fmt.Sprintf("pipeline: stage %d: %s", e.Stage, e.Err)
+}
+
+// CorrelationID returns correlation ID of the failed run or element, empty if it was not assigned.
+func (e *StageError) CorrelationID() string {
+	id, _ := e.Meta[CorrelationKey.Name()].(string)
+	return id
+}
+
+// StackTrace returns program counters of the stack of the failed run, see runtime.CallersFrames.
+// It is the stack of the panic for *PanicError, otherwise the stack of the routine which has executed the stage.
+// The trace of Err is returned instead if it has one. Stacks are captured only for runs of ctx given to
+// WithStackTraces, otherwise it returns nil.
+func (e *StageError) StackTrace() []uintptr {
+	var st interface{ StackTrace() []uintptr }
+	if errors.As(e.Err, &st) {
+		if pcs := st.StackTrace(); pcs != nil {
+			return pcs
+		}
+	}
+
+	return e.stack
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// stageError wraps err of the stage unless it is already wrapped by a nested run.
+func stageError(ctx context.Context, stage int, err error, md *Metadata, labels map[string]string) error {
+	var se *StageError
+	if errors.As(err, &se) {
+		return err
+	}
+
+	se = &StageError{Stage: stage, Err: err, Meta: md.Snapshot(), Labels: labels}
+
+	if tracing(ctx) {
+		se.stack = callers(2)
+	}
+
+	return se
+}
@@ -0,0 +1,257 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrExecutorClosed is returned by ParallelExecutor.Parallel after the executor has been closed.
+var ErrExecutorClosed = errors.New("pipeline: executor is closed")
+
+// Executor runs functions in routines, so pipelines may run on worker infrastructure of the application.
+// Parallel, Stream and middleware working in background like Timeout, Hedge, Singleflight and Start
+// run their routines by the executor attached to ctx with WithExecutor.
+//
+// Routines may wait for each other, e.g. stages of a stream or a timed out handler and its caller,
+// so an executor limiting the number of routines must allow enough of them for all pipelines it runs.
+type Executor interface {
+	// Go runs fn in a routine. It may block until the executor is able to run fn, but it must run fn eventually.
+	Go(fn func())
+}
+
+// ExecutorFunc is a function implementing Executor.
+type ExecutorFunc func(fn func())
+
+func (f ExecutorFunc) Go(fn func()) {
+	f(fn)
+}
+
+// Goroutines is Executor starting a new routine for every function, it is used without WithExecutor.
+var Goroutines Executor = ExecutorFunc(func(fn func()) { go fn() })
+
+type executorKey struct{}
+
+// WithExecutor returns ctx making pipelines executed with it run their routines by e.
+func WithExecutor(ctx context.Context, e Executor) context.Context {
+	return context.WithValue(ctx, executorKey{}, e)
+}
+
+func executorFrom(ctx context.Context) Executor {
+	if e, ok := ctx.Value(executorKey{}).(Executor); ok {
+		return e
+	}
+
+	return Goroutines
+}
+
+// spawn runs fn by the executor attached to ctx.
+func spawn(ctx context.Context, fn func()) {
+	executorFrom(ctx).Go(fn)
+}
+
+// Group is Executor tracking functions it runs like errgroup.Group, so the application can wait for them,
+// and limiting the number of them running at once. Zero value is ready to use and has no limit.
+type Group struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+}
+
+// NewGroup returns group running at most limit functions at once, Go blocks while the limit is reached.
+func NewGroup(limit int) *Group {
+	if limit <= 0 {
+		panic("limit value must be greater than zero!")
+	}
+
+	return &Group{sem: make(chan struct{}, limit)}
+}
+
+func (g *Group) Go(fn func()) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+
+	g.wg.Add(1)
+
+	go func() {
+		defer g.wg.Done()
+
+		if g.sem != nil {
+			defer func() { <-g.sem }()
+		}
+
+		fn()
+	}()
+}
+
+// Wait blocks until all functions given to Go have returned.
+func (g *Group) Wait() {
+	g.wg.Wait()
+}
+
+// BoundedExecutor is Executor running functions by a fixed set of routines living until it is closed.
+// It is safe for concurrent use.
+type BoundedExecutor struct {
+	tasks chan func()
+	done  chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	senders sync.WaitGroup
+	wg      sync.WaitGroup
+}
+
+// NewBoundedExecutor starts executor with workers routines and queue of functions waiting for them,
+// Go blocks while the queue is full.
+func NewBoundedExecutor(workers, queue int) *BoundedExecutor {
+	if workers <= 0 {
+		panic("workers value must be greater than zero!")
+	}
+
+	if queue < 0 {
+		panic("queue value must not be negative!")
+	}
+
+	e := &BoundedExecutor{tasks: make(chan func(), queue), done: make(chan struct{})}
+
+	e.wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer e.wg.Done()
+
+			for fn := range e.tasks {
+				fn()
+			}
+		}()
+	}
+
+	return e
+}
+
+// Go queues fn, it panics after the executor has been closed.
+// When the executor is closed while Go waits for the queue, fn is run by the calling routine.
+func (e *BoundedExecutor) Go(fn func()) {
+	e.mu.Lock()
+
+	if e.closed {
+		e.mu.Unlock()
+		panic("executor is closed!")
+	}
+
+	e.senders.Add(1)
+	e.mu.Unlock()
+
+	defer e.senders.Done()
+
+	select {
+	case e.tasks <- fn:
+	case <-e.done:
+		fn()
+	}
+}
+
+// Close runs queued functions and stops routines of the executor.
+func (e *BoundedExecutor) Close() {
+	e.mu.Lock()
+
+	first := !e.closed
+	if first {
+		e.closed = true
+		close(e.done)
+	}
+
+	e.mu.Unlock()
+
+	if first {
+		// Go may still be sending, the queue is closed after all calls have returned.
+		e.senders.Wait()
+		close(e.tasks)
+	}
+
+	e.wg.Wait()
+}
+
+// ParallelExecutor runs Parallel jobs on a fixed set of routines living across calls,
+// so services running many small batches do not start routines for every call.
+// It is safe for concurrent use, concurrent calls share the routines. A batch is run by the calling routine
+// when all routines of the executor are busy, so handlers may call Parallel of the same executor.
+type ParallelExecutor[T any] struct {
+	jobs  int
+	tasks chan func()
+
+	mu     sync.Mutex
+	closed bool
+	calls  sync.WaitGroup
+	wg     sync.WaitGroup
+}
+
+// NewParallelExecutor starts executor with jobs routines.
+func NewParallelExecutor[T any](jobs int) *ParallelExecutor[T] {
+	if jobs <= 0 {
+		panic("jobs value must be greater than zero!")
+	}
+
+	e := &ParallelExecutor[T]{
+		jobs:  jobs,
+		tasks: make(chan func()),
+	}
+
+	e.wg.Add(jobs)
+
+	for i := 0; i < jobs; i++ {
+		go e.work()
+	}
+
+	return e
+}
+
+func (e *ParallelExecutor[T]) work() {
+	defer e.wg.Done()
+
+	for fn := range e.tasks {
+		fn()
+	}
+}
+
+// Parallel is like Parallel with jobs of the executor, batches are run by its routines.
+func (e *ParallelExecutor[T]) Parallel(ctx context.Context, pipeline Pipeline[[]T], in []T, opts ...ParallelOption) (out []T, err error) {
+	e.mu.Lock()
+
+	if e.closed {
+		e.mu.Unlock()
+		return nil, ErrExecutorClosed
+	}
+
+	e.calls.Add(1)
+	e.mu.Unlock()
+
+	defer e.calls.Done()
+
+	exec := ExecutorFunc(func(fn func()) {
+		select {
+		case e.tasks <- fn:
+		default:
+			fn()
+		}
+	})
+
+	return parallel(ctx, pipeline, in, e.jobs, exec, opts)
+}
+
+// Close waits for running calls and stops routines of the executor, calls made since then fail
+// with ErrExecutorClosed.
+func (e *ParallelExecutor[T]) Close() {
+	e.mu.Lock()
+	closing := !e.closed
+	e.closed = true
+	e.mu.Unlock()
+
+	e.calls.Wait()
+
+	if closing {
+		close(e.tasks)
+	}
+
+	e.wg.Wait()
+}
@@ -0,0 +1,85 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParallelExecutorNested(t *testing.T) {
+	e := NewParallelExecutor[int](1)
+
+	inc := func(ctx context.Context, in []int) ([]int, error) {
+		for i := range in {
+			in[i]++
+		}
+
+		return in, nil
+	}
+
+	// Every batch calls Parallel of the same executor while its only routine is busy with the outer batch.
+	nested := func(ctx context.Context, in []int) ([]int, error) {
+		return e.Parallel(ctx, Pipeline[[]int]{inc}, in)
+	}
+
+	out, err := e.Parallel(context.Background(), Pipeline[[]int]{nested}, []int{0, 1, 2, 3}, ChunkSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []int{1, 2, 3, 4}; !slices.Equal(out, want) {
+		t.Fatalf("got %v, want %v", out, want)
+	}
+
+	e.Close()
+
+	if _, err := e.Parallel(context.Background(), Pipeline[[]int]{inc}, []int{0}); !errors.Is(err, ErrExecutorClosed) {
+		t.Fatalf("got error %v, want ErrExecutorClosed", err)
+	}
+}
+
+func TestBoundedExecutorClose(t *testing.T) {
+	defer checkLeaks(t)()
+
+	e := NewBoundedExecutor(1, 0)
+
+	ran := make(chan struct{})
+
+	// The only routine waits for the function queued after it, so Go blocks until Close runs it by the caller.
+	e.Go(func() { <-ran })
+
+	sent := make(chan struct{})
+
+	go func() {
+		defer close(sent)
+		e.Go(func() { close(ran) })
+	}()
+
+	// Lets Go start waiting for the queue.
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+
+	go func() {
+		defer close(closed)
+		e.Close()
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close has not returned")
+	}
+
+	<-sent
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Go of the closed executor has not panicked")
+		}
+	}()
+
+	e.Go(func() {})
+}
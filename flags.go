@@ -0,0 +1,188 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FlagProvider resolves feature flags, e.g. from environment, configuration or a feature management service.
+// Providers targeting flags to tenants or users can read them from metadata of ctx.
+type FlagProvider interface {
+	// Enabled reports whether flag is on.
+	Enabled(ctx context.Context, flag string) (bool, error)
+}
+
+// FlagProviderFunc is a function implementing FlagProvider.
+type FlagProviderFunc func(ctx context.Context, flag string) (bool, error)
+
+func (f FlagProviderFunc) Enabled(ctx context.Context, flag string) (bool, error) {
+	return f(ctx, flag)
+}
+
+type flagsKey struct{}
+
+// WithFlags returns ctx making Flagged stages of pipelines executed with it resolve flags by provider.
+func WithFlags(ctx context.Context, provider FlagProvider) context.Context {
+	return context.WithValue(ctx, flagsKey{}, provider)
+}
+
+func flagsFrom(ctx context.Context) FlagProvider {
+	p, _ := ctx.Value(flagsKey{}).(FlagProvider)
+	return p
+}
+
+// FlagOption configures Flagged.
+type FlagOption func(*flagConfig)
+
+type flagConfig struct {
+	perRun   bool
+	disabled any
+}
+
+// FlagPerRun makes the flag be resolved once per run of Execute or stream element: the first resolved value
+// is kept in metadata, so all stages guarded by the flag agree even when it is toggled in the middle of the run.
+// By default the flag is resolved on every call.
+func FlagPerRun() FlagOption {
+	return func(c *flagConfig) {
+		c.perRun = true
+	}
+}
+
+// FlagElse sets handler called instead of the guarded one while the flag is off.
+func FlagElse[T any](handler HandlerFunc[T]) FlagOption {
+	return func(c *flagConfig) {
+		c.disabled = handler
+	}
+}
+
+// flagKeys holds metadata keys of flags resolved per run by names of flags.
+var flagKeys sync.Map
+
+func flagKey(flag string) *MetaKey[bool] {
+	k, _ := flagKeys.LoadOrStore(flag, NewMetaKey[bool]("flag:"+flag))
+	return k.(*MetaKey[bool])
+}
+
+// Flagged returns handler calling handler only while flag is on, otherwise the value is passed further unchanged
+// or to the handler given by FlagElse. Flags are resolved by the provider attached to ctx with WithFlags,
+// there are no flags on without it. Errors of the provider fail the stage.
+func Flagged[T any](flag string, handler HandlerFunc[T], opts ...FlagOption) HandlerFunc[T] {
+	var cfg flagConfig
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	disabled, ok := cfg.disabled.(HandlerFunc[T])
+	if !ok && cfg.disabled != nil {
+		panic("else handler must have the type of the guarded handler!")
+	}
+
+	return flagged(flag, handler, disabled, cfg.perRun)
+}
+
+func flagged[T any](flag string, handler, disabled HandlerFunc[T], perRun bool) HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		on, err := enabled(ctx, flag, perRun)
+		if err != nil {
+			return out, err
+		}
+
+		switch {
+		case on:
+			return handler(ctx, in)
+		case disabled != nil:
+			return disabled(ctx, in)
+		}
+
+		return in, nil
+	}
+
+	clone := func() any { return flagged(flag, cloneHandler(handler), cloneHandler(disabled), perRun) }
+
+	return describe(fn, describeInfo{middleware: fmt.Sprintf("Flagged(%s)", flag), inner: handler, clone: clone})
+}
+
+// enabled resolves flag for the call, perRun reuses the value resolved earlier in the run.
+func enabled(ctx context.Context, flag string, perRun bool) (bool, error) {
+	var key *MetaKey[bool]
+
+	if perRun {
+		key = flagKey(flag)
+
+		if on, ok := key.Get(ctx); ok {
+			return on, nil
+		}
+	}
+
+	provider := flagsFrom(ctx)
+	if provider == nil {
+		return false, nil
+	}
+
+	on, err := provider.Enabled(ctx, flag)
+	if err != nil {
+		return false, fmt.Errorf("pipeline: flag %q: %w", flag, err)
+	}
+
+	if key != nil {
+		key.Set(ctx, on)
+	}
+
+	return on, nil
+}
+
+// Flags is FlagProvider keeping flags in memory, e.g. loaded from configuration and toggled on its reload.
+// Unknown flags are off. Zero value is ready to use, Flags is safe for concurrent use.
+type Flags struct {
+	mu    sync.RWMutex
+	flags map[string]bool
+}
+
+// Set turns flag on or off.
+func (f *Flags) Set(flag string, on bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.flags == nil {
+		f.flags = map[string]bool{}
+	}
+
+	f.flags[flag] = on
+}
+
+func (f *Flags) Enabled(ctx context.Context, flag string) (bool, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.flags[flag], nil
+}
+
+// EnvFlags returns FlagProvider reading flags from environment variables named by prefix and the flag name
+// in upper case with '-' and '.' replaced by '_', e.g. flag "new-parser" with prefix "APP_" is read from
+// APP_NEW_PARSER. Values are parsed by strconv.ParseBool, unset variables mean off.
+func EnvFlags(prefix string) FlagProvider {
+	replacer := strings.NewReplacer("-", "_", ".", "_")
+
+	fn := func(ctx context.Context, flag string) (bool, error) {
+		name := prefix + strings.ToUpper(replacer.Replace(flag))
+
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return false, nil
+		}
+
+		on, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, fmt.Errorf("environment variable %s: %w", name, err)
+		}
+
+		return on, nil
+	}
+
+	return FlagProviderFunc(fn)
+}
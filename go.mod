@@ -1,3 +1,3 @@
 module github.com/WinPooh32/pipe
 
-go 1.18
+go 1.21
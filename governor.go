@@ -0,0 +1,285 @@
+package pipe
+
+import (
+	"context"
+	"math"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// DefaultGovernorInterval is the period of sampling runtime metrics when no GovernInterval option is given.
+const DefaultGovernorInterval = 100 * time.Millisecond
+
+// governorFloor is the least share of concurrency a governor leaves under pressure.
+const governorFloor = 1.0 / 16
+
+const (
+	gcPausesMetric   = "/gc/pauses:seconds"
+	goroutinesMetric = "/sched/goroutines:goroutines"
+)
+
+// Pressure is a sample of runtime metrics.
+type Pressure struct {
+	// Heap is memory occupied by heap objects.
+	Heap uint64
+	// GCPause is the longest stop-the-world pause of GC since the previous sample.
+	GCPause    time.Duration
+	Goroutines int
+}
+
+// GovernorOption configures Governor.
+type GovernorOption func(*governorConfig)
+
+type governorConfig struct {
+	heap       uint64
+	gcPause    time.Duration
+	goroutines int
+	interval   time.Duration
+	onChange   func(p Pressure, share float64)
+}
+
+// GovernHeap makes Governor consider the process under pressure while heap objects occupy more than limit bytes.
+func GovernHeap(limit uint64) GovernorOption {
+	return func(c *governorConfig) {
+		c.heap = limit
+	}
+}
+
+// GovernGCPause makes Governor consider the process under pressure when a GC pause has been longer than d.
+func GovernGCPause(d time.Duration) GovernorOption {
+	return func(c *governorConfig) {
+		c.gcPause = d
+	}
+}
+
+// GovernGoroutines makes Governor consider the process under pressure while there are more than n goroutines.
+func GovernGoroutines(n int) GovernorOption {
+	return func(c *governorConfig) {
+		c.goroutines = n
+	}
+}
+
+// GovernInterval sets how often Governor samples runtime metrics.
+func GovernInterval(d time.Duration) GovernorOption {
+	if d <= 0 {
+		panic("interval value must be greater than zero!")
+	}
+
+	return func(c *governorConfig) {
+		c.interval = d
+	}
+}
+
+// OnGovern sets hook called after Governor has changed the allowed share of concurrency.
+func OnGovern(fn func(p Pressure, share float64)) GovernorOption {
+	return func(c *governorConfig) {
+		c.onChange = fn
+	}
+}
+
+// Governor reduces concurrency of Parallel and stream stages under memory, GC or goroutine pressure.
+// Every interval it samples runtime/metrics: under pressure it halves the allowed share of concurrency,
+// otherwise it raises the share by a tenth until concurrency is restored. At least one routine always runs,
+// so the work slows down instead of failing. Governor is safe for concurrent use, share it between
+// Parallel calls and streams of a process with Govern and StageGovern.
+type Governor struct {
+	cfg governorConfig
+
+	mu       sync.Mutex
+	share    float64
+	sampled  time.Time
+	pressure Pressure
+	pauses   []uint64
+	// changed is closed and replaced when the share is raised or a permit is released.
+	changed chan struct{}
+}
+
+// NewGovernor returns governor, it is not under pressure until a limit is set by options.
+func NewGovernor(opts ...GovernorOption) *Governor {
+	cfg := governorConfig{interval: DefaultGovernorInterval}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Governor{cfg: cfg, share: 1, changed: make(chan struct{})}
+}
+
+// Pressure returns the last sample of runtime metrics.
+func (g *Governor) Pressure() Pressure {
+	g.mu.Lock()
+	report := g.sample(time.Now())
+	p := g.pressure
+	g.mu.Unlock()
+
+	report()
+
+	return p
+}
+
+// Limit returns the allowed part of n concurrent routines, at least one.
+func (g *Governor) Limit(n int) int {
+	g.mu.Lock()
+	report := g.sample(time.Now())
+	limit := g.limit(n)
+	g.mu.Unlock()
+
+	report()
+
+	return limit
+}
+
+// limit must be called with locked mu.
+func (g *Governor) limit(n int) int {
+	return max(1, int(math.Ceil(float64(n)*g.share)))
+}
+
+// sample updates the share if interval has elapsed since the previous sample, must be called with locked mu.
+// It returns function reporting the change of the share to OnGovern hook, call it after mu is unlocked,
+// so the hook may use the governor.
+func (g *Governor) sample(now time.Time) (report func()) {
+	report = func() {}
+
+	if now.Sub(g.sampled) < g.cfg.interval {
+		return report
+	}
+
+	g.sampled = now
+
+	samples := []metrics.Sample{{Name: heapObjectsMetric}, {Name: gcPausesMetric}, {Name: goroutinesMetric}}
+	metrics.Read(samples)
+
+	p := Pressure{}
+
+	if v := samples[0].Value; v.Kind() == metrics.KindUint64 {
+		p.Heap = v.Uint64()
+	}
+
+	if v := samples[1].Value; v.Kind() == metrics.KindFloat64Histogram {
+		p.GCPause = g.longestPause(v.Float64Histogram())
+	}
+
+	if v := samples[2].Value; v.Kind() == metrics.KindUint64 {
+		p.Goroutines = int(v.Uint64())
+	}
+
+	g.pressure = p
+
+	share := g.share
+
+	if g.pressured(p) {
+		share = max(share/2, governorFloor)
+	} else {
+		share = min(share+0.1, 1)
+	}
+
+	if share == g.share {
+		return report
+	}
+
+	if share > g.share {
+		g.notify()
+	}
+
+	g.share = share
+
+	if g.cfg.onChange != nil {
+		report = func() { g.cfg.onChange(p, share) }
+	}
+
+	return report
+}
+
+func (g *Governor) pressured(p Pressure) bool {
+	return (g.cfg.heap > 0 && p.Heap > g.cfg.heap) ||
+		(g.cfg.gcPause > 0 && p.GCPause > g.cfg.gcPause) ||
+		(g.cfg.goroutines > 0 && p.Goroutines > g.cfg.goroutines)
+}
+
+// longestPause returns upper bound of the longest pause counted since the previous sample.
+func (g *Governor) longestPause(h *metrics.Float64Histogram) time.Duration {
+	var longest float64
+
+	for i, n := range h.Counts {
+		if i < len(g.pauses) && n > g.pauses[i] {
+			longest = h.Buckets[i+1]
+		}
+	}
+
+	g.pauses = append(g.pauses[:0], h.Counts...)
+
+	if math.IsInf(longest, 1) {
+		longest = h.Buckets[len(h.Buckets)-2]
+	}
+
+	return time.Duration(longest * float64(time.Second))
+}
+
+// notify wakes routines waiting for a permit, must be called with locked mu.
+func (g *Governor) notify() {
+	close(g.changed)
+	g.changed = make(chan struct{})
+}
+
+// governorSemaphore limits n routines to the part allowed by the governor.
+type governorSemaphore struct {
+	g     *Governor
+	n     int
+	inUse int
+}
+
+// acquire waits for a permit until stop or ctx is done, it returns false then. Nil semaphore has no limit.
+func (s *governorSemaphore) acquire(ctx context.Context, stop <-chan struct{}) bool {
+	if s == nil {
+		return true
+	}
+
+	g := s.g
+
+	for {
+		g.mu.Lock()
+
+		report := g.sample(time.Now())
+
+		if s.inUse < g.limit(s.n) {
+			s.inUse++
+			g.mu.Unlock()
+			report()
+
+			return true
+		}
+
+		changed := g.changed
+
+		g.mu.Unlock()
+		report()
+
+		timer := time.NewTimer(g.cfg.interval)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-stop:
+			timer.Stop()
+			return false
+		case <-changed:
+		case <-timer.C:
+		}
+
+		timer.Stop()
+	}
+}
+
+func (s *governorSemaphore) release() {
+	if s == nil {
+		return
+	}
+
+	s.g.mu.Lock()
+	defer s.g.mu.Unlock()
+
+	s.inUse--
+	s.g.notify()
+}
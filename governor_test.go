@@ -0,0 +1,78 @@
+package pipe
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGovernor(t *testing.T) {
+	// The metric counts routines of the runtime too, so the limit is set above its current value.
+	base := NewGovernor().Pressure().Goroutines
+
+	var (
+		g      *Governor
+		shares []float64
+		limits []int
+	)
+
+	// The hook calls the governor, so it must be called without the governor locked.
+	onChange := func(p Pressure, share float64) {
+		shares = append(shares, share)
+		limits = append(limits, g.Limit(16))
+	}
+
+	g = NewGovernor(GovernGoroutines(base+4), GovernInterval(20*time.Millisecond), OnGovern(onChange))
+
+	release := make(chan struct{})
+
+	for i := 0; i < 8; i++ {
+		go func() { <-release }()
+	}
+
+	tests := []struct {
+		name   string
+		before func()
+		want   []int
+	}{
+		{name: "back-off", want: []int{8, 4, 2, 1, 1, 1}},
+		{
+			name:   "recovery",
+			before: func() { close(release); time.Sleep(10 * time.Millisecond) },
+			want:   []int{3, 5, 6, 8, 9, 11},
+		},
+	}
+
+	for _, tt := range tests {
+		if tt.before != nil {
+			tt.before()
+		}
+
+		var got []int
+
+		for range tt.want {
+			time.Sleep(25 * time.Millisecond)
+			got = append(got, g.Limit(16))
+		}
+
+		if !slices.Equal(got, tt.want) {
+			t.Fatalf("%s: got limits %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if len(shares) == 0 || !slices.Equal(limits, limitsOf(shares, 16)) {
+		t.Fatalf("got limits %v reported for shares %v", limits, shares)
+	}
+}
+
+// limitsOf returns limits of n routines for shares.
+func limitsOf(shares []float64, n int) []int {
+	limits := make([]int, len(shares))
+
+	for i, share := range shares {
+		g := &Governor{share: share}
+		limits[i] = g.limit(n)
+	}
+
+	return limits
+}
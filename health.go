@@ -0,0 +1,267 @@
+package pipe
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultStallTimeout is the period without progress after which a busy pool or stream is reported as stalled.
+const DefaultStallTimeout = 30 * time.Second
+
+// HealthState is a state of a long-running pool or stream.
+type HealthState int
+
+const (
+	// HealthStarting means the stream has not been run yet.
+	HealthStarting HealthState = iota
+	// HealthRunning means values are accepted and processed.
+	HealthRunning
+	// HealthDraining means no new values are accepted and the remaining ones are being processed.
+	HealthDraining
+	// HealthStalled means there are values in work, but none has been completed within the stall timeout.
+	HealthStalled
+	// HealthStopped means the pool is closed or the stream has returned.
+	HealthStopped
+	// HealthPaused means taking of new values is paused, see Pause.
+	HealthPaused
+)
+
+func (s HealthState) String() string {
+	switch s {
+	case HealthStarting:
+		return "starting"
+	case HealthRunning:
+		return "running"
+	case HealthDraining:
+		return "draining"
+	case HealthStalled:
+		return "stalled"
+	case HealthStopped:
+		return "stopped"
+	case HealthPaused:
+		return "paused"
+	default:
+		return "unknown"
+	}
+}
+
+// Health is a snapshot of a pool or stream state for readiness and liveness probes.
+type Health struct {
+	State HealthState
+	// LastProgress is the time a value has been completed last, zero if there was none.
+	LastProgress time.Time
+	// LastError is the error of the last failed value if any.
+	LastError error
+}
+
+// Healthy reports whether the state is starting, running, draining or paused.
+func (h Health) Healthy() bool {
+	return h.State != HealthStalled && h.State != HealthStopped
+}
+
+// healthTracker collects health of a pool or stream, it is safe for concurrent use.
+type healthTracker struct {
+	timeout time.Duration
+
+	mu    sync.Mutex
+	state HealthState
+	last  time.Time
+	err   error
+	// inflight is the number of values in work since busy.
+	inflight int
+	busy     time.Time
+	// idle is closed when inflight drops to zero.
+	idle chan struct{}
+	// resumed is closed when the paused tracker is resumed.
+	paused  bool
+	resumed chan struct{}
+	onPause func(paused bool)
+}
+
+// opened is returned by healthTracker.ready when it is not paused.
+var opened = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+
+	return ch
+}()
+
+// start resets the tracker for a new run.
+func (h *healthTracker) start() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.state = HealthRunning
+	h.inflight = 0
+	h.notifyIdle()
+}
+
+func (h *healthTracker) set(state HealthState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.state = state
+}
+
+// begin accounts a value taken in work, it waits while the tracker is paused.
+// It returns false if stop is closed meanwhile.
+func (h *healthTracker) begin(stop <-chan struct{}) bool {
+	for {
+		h.mu.Lock()
+
+		if !h.paused {
+			if h.inflight == 0 {
+				h.busy = time.Now()
+			}
+
+			h.inflight++
+			h.mu.Unlock()
+
+			return true
+		}
+
+		resumed := h.resumed
+
+		h.mu.Unlock()
+
+		select {
+		case <-stop:
+			return false
+		case <-resumed:
+		}
+	}
+}
+
+// ready returns channel which is closed while the tracker is not paused.
+func (h *healthTracker) ready() <-chan struct{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.paused {
+		return opened
+	}
+
+	return h.resumed
+}
+
+// end accounts a completed value failed with err if it is not nil.
+func (h *healthTracker) end(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.inflight--
+	h.last = time.Now()
+
+	if h.inflight == 0 {
+		h.notifyIdle()
+	}
+
+	if err != nil {
+		h.err = err
+	}
+}
+
+// setPaused pauses or resumes taking of values in work and calls onPause if the state has changed.
+func (h *healthTracker) setPaused(paused bool) {
+	h.mu.Lock()
+
+	if h.paused == paused {
+		h.mu.Unlock()
+		return
+	}
+
+	h.paused = paused
+
+	if paused {
+		h.resumed = make(chan struct{})
+	} else {
+		close(h.resumed)
+	}
+
+	onPause := h.onPause
+
+	h.mu.Unlock()
+
+	if onPause != nil {
+		onPause(paused)
+	}
+}
+
+// pause pauses taking of values and waits until values in work are done or ctx is done.
+func (h *healthTracker) pause(ctx context.Context) error {
+	h.setPaused(true)
+	return h.waitIdle(ctx)
+}
+
+// waitIdle waits until there are no values in work.
+func (h *healthTracker) waitIdle(ctx context.Context) error {
+	h.mu.Lock()
+
+	if h.inflight <= 0 {
+		h.mu.Unlock()
+		return nil
+	}
+
+	if h.idle == nil {
+		h.idle = make(chan struct{})
+	}
+
+	idle := h.idle
+
+	h.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return cause(ctx)
+	case <-idle:
+		return nil
+	}
+}
+
+// notifyIdle wakes waiters of waitIdle, must be called with locked mu.
+func (h *healthTracker) notifyIdle() {
+	if h.idle != nil {
+		close(h.idle)
+		h.idle = nil
+	}
+}
+
+// fail records err of the run.
+func (h *healthTracker) fail(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.err = err
+}
+
+func (h *healthTracker) report() Health {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r := Health{State: h.state, LastProgress: h.last, LastError: h.err}
+
+	if h.paused && h.state == HealthRunning {
+		r.State = HealthPaused
+	}
+
+	if h.inflight <= 0 || (h.state != HealthRunning && h.state != HealthDraining) {
+		return r
+	}
+
+	timeout := h.timeout
+	if timeout == 0 {
+		timeout = DefaultStallTimeout
+	}
+
+	since := h.busy
+	if h.last.After(since) {
+		since = h.last
+	}
+
+	if time.Since(since) > timeout {
+		r.State = HealthStalled
+	}
+
+	return r
+}
@@ -0,0 +1,78 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Hedge returns handler which starts another attempt of handler when no attempt has completed within delay,
+// up to maxHedges extra attempts. The first succeeded attempt wins and the others are canceled.
+// A failed attempt starts the next one immediately, the last error is returned when all attempts fail.
+// An attempt returning ErrSkipRest or ErrStop has succeeded, the sentinel is returned with its output.
+// Attempts receive the same input concurrently, so handler must not modify it.
+func Hedge[T any](handler HandlerFunc[T], delay time.Duration, maxHedges int) HandlerFunc[T] {
+	if maxHedges < 0 {
+		panic("maxHedges value must not be negative!")
+	}
+
+	type result struct {
+		out T
+		err error
+	}
+
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		results := make(chan result, maxHedges+1)
+
+		launch := func() {
+			spawn(ctx, func() {
+				out, err := call(ctx, handler, in)
+				results <- result{out, err}
+			})
+		}
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		launch()
+
+		launched, failed := 1, 0
+
+		for {
+			select {
+			case <-ctx.Done():
+				return out, cause(ctx)
+
+			case <-timer.C:
+				if launched <= maxHedges {
+					launch()
+					launched++
+					timer.Reset(delay)
+				}
+
+			case r := <-results:
+				if r.err == nil || finished(r.err) {
+					return r.out, r.err
+				}
+
+				failed++
+				err = r.err
+
+				if launched <= maxHedges {
+					launch()
+					launched++
+					timer.Reset(delay)
+				} else if failed == launched {
+					return out, err
+				}
+			}
+		}
+	}
+
+	clone := func() any { return Hedge(cloneHandler(handler), delay, maxHedges) }
+
+	return describe(fn, describeInfo{middleware: fmt.Sprintf("Hedge(delay=%s, max=%d)", delay, maxHedges), inner: handler, clone: clone})
+}
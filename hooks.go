@@ -0,0 +1,155 @@
+package pipe
+
+import (
+	"context"
+	"time"
+)
+
+// EventKind is a kind of Event.
+type EventKind int
+
+const (
+	// EventFallback is emitted when a stage result has been substituted by a fallback.
+	EventFallback EventKind = iota + 1
+	// EventStageStart is emitted by Execute and Stream before a stage handles a value.
+	EventStageStart
+	// EventStageDone is emitted by Execute and Stream after a stage has handled a value.
+	EventStageDone
+	// EventDiff is emitted by stages of DiffStages which have changed their value.
+	EventDiff
+	// EventDropped is emitted when a value has been dropped, like by DropExpired.
+	EventDropped
+	// EventSlow is emitted by Watchdog when a stage has taken much longer than usually.
+	EventSlow
+	// EventStalled is emitted by Watchdog when a busy stage has not handled a value within the stall timeout.
+	EventStalled
+	// EventScaleUp and EventScaleDown are emitted by Pool after it has started or stopped workers, see PoolEvents.
+	EventScaleUp
+	EventScaleDown
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventFallback:
+		return "fallback"
+	case EventStageStart:
+		return "stage start"
+	case EventStageDone:
+		return "stage done"
+	case EventDiff:
+		return "diff"
+	case EventDropped:
+		return "dropped"
+	case EventSlow:
+		return "slow"
+	case EventStalled:
+		return "stalled"
+	case EventScaleUp:
+		return "scale up"
+	case EventScaleDown:
+		return "scale down"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes something noticeable happened during a run.
+type Event struct {
+	Kind EventKind
+	Time time.Time
+	// Stage is the index of the running stage, it is -1 when it is unknown.
+	Stage int
+	// In is the input of the stage for stage events.
+	In any
+	// Out is the output of the stage for EventStageDone.
+	Out any
+	// Duration is the time the stage has taken for EventStageDone and EventSlow,
+	// the time without progress for EventStalled.
+	Duration time.Duration
+	// Baseline is the usual time the stage takes for EventSlow.
+	Baseline time.Duration
+	// Workers is the number of workers after EventScaleUp and EventScaleDown.
+	Workers int
+	// Diff describes changes done by the stage for EventDiff.
+	Diff string
+	// Err is the error which caused the event if any.
+	Err error
+	// Meta is the snapshot of the run or element metadata.
+	Meta map[string]any
+	// Labels are annotations of the stage for stage events, see Annotate.
+	Labels map[string]string
+	// CorrelationID is the correlation ID of the run or element, see Correlate.
+	CorrelationID string
+}
+
+// Hook receives events of runs. Hook is called synchronously by the emitting handler,
+// it must be safe for concurrent use.
+type Hook func(ctx context.Context, e Event)
+
+type hookKey struct{}
+
+// WithHook returns ctx making runs executed with it report events to hook in addition to hooks of ctx.
+func WithHook(ctx context.Context, hook Hook) context.Context {
+	if prev, ok := ctx.Value(hookKey{}).(Hook); ok {
+		next := hook
+		hook = func(ctx context.Context, e Event) {
+			prev(ctx, e)
+			next(ctx, e)
+		}
+	}
+
+	return context.WithValue(ctx, hookKey{}, hook)
+}
+
+func hookFrom(ctx context.Context) Hook {
+	hook, _ := ctx.Value(hookKey{}).(Hook)
+	return hook
+}
+
+// emit reports event to hooks of ctx with the index of the running stage of Execute.
+func emit(ctx context.Context, e Event) {
+	hook := hookFrom(ctx)
+	if hook == nil {
+		return
+	}
+
+	stage := -1
+
+	if s := scopeFrom(ctx); s != nil && !s.shared {
+		stage = int(s.stage.Load())
+	}
+
+	emitTo(ctx, hook, stage, e)
+}
+
+func emitTo(ctx context.Context, hook Hook, stage int, e Event) {
+	e.Time = time.Now()
+	e.Stage = stage
+	e.Meta = MetadataFrom(ctx).Snapshot()
+	e.CorrelationID = CorrelationID(ctx)
+
+	hook(ctx, e)
+}
+
+// handle calls handler of the stage reporting stage events with labels to hook if it is not nil.
+func handle[T any](ctx context.Context, hook Hook, stage int, handler HandlerFunc[T], labels map[string]string, in T) (out T, err error) {
+	if hook == nil {
+		return handler(ctx, in)
+	}
+
+	emitTo(ctx, hook, stage, Event{Kind: EventStageStart, In: in, Labels: labels})
+
+	start := time.Now()
+
+	out, err = handler(ctx, in)
+
+	e := Event{Kind: EventStageDone, In: in, Out: out, Duration: time.Since(start), Err: err, Labels: labels}
+
+	if finished(err) {
+		e.Err = nil
+	}
+
+	emitTo(ctx, hook, stage, e)
+
+	return out, err
+}
@@ -0,0 +1,37 @@
+package pipe
+
+import "context"
+
+// Idempotent returns handler which skips values whose key has already been processed successfully,
+// skipped values are passed further unchanged. Keys are marked in store after handler succeeds,
+// so failed values are processed again on redelivery. ErrSkipRest and ErrStop of handler mark the key too.
+// Duplicates processed concurrently may both reach handler.
+func Idempotent[T any](handler HandlerFunc[T], key func(T) string, store StateStore) HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		k := key(in)
+
+		_, done, err := store.Load(ctx, k)
+		if err != nil {
+			return out, err
+		}
+
+		if done {
+			return in, nil
+		}
+
+		out, err = handler(ctx, in)
+		if err != nil && !finished(err) {
+			return out, err
+		}
+
+		if serr := store.Store(ctx, k, nil); serr != nil {
+			return out, serr
+		}
+
+		return out, err
+	}
+
+	clone := func() any { return Idempotent(cloneHandler(handler), key, store) }
+
+	return describe(fn, describeInfo{middleware: "Idempotent", inner: handler, clone: clone})
+}
@@ -0,0 +1,80 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrNilValue is returned by stages of InPlace pipelines when the handler has returned nil pointer.
+var ErrNilValue = errors.New("pipeline: nil value")
+
+// Cloner returns deep copy of v.
+type Cloner[T any] func(v T) T
+
+// CloneInput makes Parallel pass every batch copies of its elements made by clone, so batches do not share
+// data pointed by elements with each other and with the caller. The type of clone must be Cloner[T]
+// for Parallel over []T.
+func CloneInput[T any](clone Cloner[T]) ParallelOption {
+	return func(c *parallelConfig) {
+		c.clone = clone
+	}
+}
+
+// cloneBatch returns in or its copy made by the Cloner of c.
+func cloneBatch[T any](c *parallelConfig, in []T) []T {
+	if c.clone == nil {
+		return in
+	}
+
+	clone, ok := c.clone.(Cloner[T])
+	if !ok {
+		panic(fmt.Sprintf("clone must be Cloner[%T]!", *new(T)))
+	}
+
+	out := make([]T, len(in))
+
+	for i, v := range in {
+		out[i] = clone(v)
+	}
+
+	return out
+}
+
+// InPlace returns copy of pipeline over pointers which guarantees that the value entering the first stage
+// flows through all stages: when a handler returns another pointer, the value it points to is copied into
+// the incoming one. Handlers returning nil fail with ErrNilValue.
+func InPlace[T any](pipeline Pipeline[*T]) Pipeline[*T] {
+	p := make(Pipeline[*T], len(pipeline))
+
+	for i, handler := range pipeline {
+		p[i] = inPlace(handler)
+	}
+
+	return p
+}
+
+func inPlace[T any](handler HandlerFunc[*T]) HandlerFunc[*T] {
+	fn := func(ctx context.Context, in *T) (out *T, err error) {
+		out, err = handler(ctx, in)
+		if in == nil || out == in {
+			return out, err
+		}
+
+		if out == nil {
+			if err == nil {
+				err = ErrNilValue
+			}
+
+			return in, err
+		}
+
+		*in = *out
+
+		return in, err
+	}
+
+	clone := func() any { return inPlace(cloneHandler(handler)) }
+
+	return describe(fn, describeInfo{middleware: "InPlace", inner: handler, clone: clone})
+}
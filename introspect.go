@@ -0,0 +1,263 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// StageInfo describes a stage of a pipeline or a stream.
+type StageInfo struct {
+	Index int
+	// Name is the name given by Named or the name of the handler function.
+	Name string
+	// Middleware lists wrappers of the handler from the outermost one, e.g. "Retry(attempts=3)".
+	Middleware []string
+	// Options holds options of a stream stage.
+	Options map[string]any
+	// Labels are annotations of the stage given by Annotate.
+	Labels map[string]string
+}
+
+func (s StageInfo) String() string {
+	var b strings.Builder
+
+	for _, m := range s.Middleware {
+		b.WriteString(m)
+		b.WriteString(" > ")
+	}
+
+	b.WriteString(s.Name)
+
+	return b.String()
+}
+
+// Stages describes stages of the pipeline.
+func (p Pipeline[T]) Stages() []StageInfo {
+	stages := make([]StageInfo, len(p))
+
+	for i, handler := range p {
+		stages[i] = describeHandler(handler)
+		stages[i].Index = i
+	}
+
+	return stages
+}
+
+// Clone returns copy of the pipeline which stages do not share state with the stages of p:
+// Singleflight gets own map of calls in flight, Cached over *MemoryCache gets copy of the cache,
+// compiled pipelines get own stats. Stages wrapped by Named, Annotate, Retry, Timeout, Hedge, ForEach,
+// Expire, OnError, InPlace, Compensate, Idempotent, Sampled, Flagged and DiffStages are cloned with their wrappers,
+// pipelines of InTx are cloned.
+//
+// Handlers of the package are safe for concurrent calls, so a pipeline may be shared by concurrent Execute
+// calls as is; clone it when its stages must not see each other's cached or in-flight calls, e.g. per tenant
+// or per test. Clone does not copy what is given to constructors: user handlers, stores, limiters, budgets,
+// sources of random numbers, MutablePipeline and stages of other wrappers stay shared.
+func (p Pipeline[T]) Clone() Pipeline[T] {
+	c := make(Pipeline[T], len(p))
+
+	for i, handler := range p {
+		c[i] = cloneHandler(handler)
+	}
+
+	return c
+}
+
+func (p Pipeline[T]) String() string {
+	return formatStages(p.Stages())
+}
+
+func formatStages(stages []StageInfo) string {
+	names := make([]string, len(stages))
+
+	for i, s := range stages {
+		names[i] = s.String()
+	}
+
+	return "(" + strings.Join(names, " | ") + ")"
+}
+
+// Named returns handler reported by introspection under name.
+func Named[T any](name string, handler HandlerFunc[T]) HandlerFunc[T] {
+	clone := func() any { return Named(name, cloneHandler(handler)) }
+
+	return describe(handler, describeInfo{name: name, inner: handler, clone: clone})
+}
+
+// Annotate returns handler carrying labels, e.g. owner, SLA or cost class of the stage.
+// Labels are reported by introspection, hook events and StageError. Labels of outer annotations
+// take precedence over the inner ones.
+func Annotate[T any](handler HandlerFunc[T], labels map[string]string) HandlerFunc[T] {
+	copied := make(map[string]string, len(labels))
+
+	for k, v := range labels {
+		copied[k] = v
+	}
+
+	clone := func() any { return Annotate(cloneHandler(handler), copied) }
+
+	return describe(handler, describeInfo{labels: copied, inner: handler, clone: clone})
+}
+
+// describeInfo is what a described handler reports about itself.
+type describeInfo struct {
+	name       string
+	middleware string
+	labels     map[string]string
+	inner      any
+	// clone returns copy of the handler with its own state, nil means copies share the handler.
+	clone func() any
+}
+
+// describeContext is passed to described handlers instead of a run context to query their info.
+type describeContext struct {
+	context.Context
+	info describeInfo
+	// handler is the handler wrapped by describe.
+	handler any
+}
+
+// described holds code pointers of closures returned by describe.
+var described sync.Map
+
+// describe returns handler reporting info to introspection, it is meant for wrapping handlers
+// returned by middleware, inner is the wrapped handler or value.
+func describe[T any](handler HandlerFunc[T], info describeInfo) HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		if d, ok := ctx.(*describeContext); ok {
+			d.info = info
+			d.handler = handler
+
+			return out, nil
+		}
+
+		return handler(ctx, in)
+	}
+
+	described.LoadOrStore(reflect.ValueOf(fn).Pointer(), struct{}{})
+
+	return fn
+}
+
+// cloneHandler returns copy of the handler with its own state, handlers without state are returned as is.
+func cloneHandler[T any](handler HandlerFunc[T]) HandlerFunc[T] {
+	d, ok := query(handler)
+	if !ok || d.info.clone == nil {
+		return handler
+	}
+
+	if h, ok := d.info.clone().(HandlerFunc[T]); ok {
+		return h
+	}
+
+	return handler
+}
+
+// describeHandler unwraps described handlers collecting their info.
+func describeHandler(handler any) (info StageInfo) {
+	for depth := 0; depth < maxDiffDepth; depth++ {
+		d, ok := query(handler)
+		if !ok {
+			if info.Name == "" {
+				info.Name = handlerName(handler)
+			}
+
+			return info
+		}
+
+		if info.Name == "" {
+			info.Name = d.info.name
+		}
+
+		if d.info.middleware != "" {
+			info.Middleware = append(info.Middleware, d.info.middleware)
+		}
+
+		info.Labels = mergeLabels(info.Labels, d.info.labels)
+
+		handler = d.info.inner
+	}
+
+	return info
+}
+
+// query returns info of the handler wrapped by describe.
+func query(handler any) (*describeContext, bool) {
+	v := reflect.ValueOf(handler)
+	if !v.IsValid() || v.Kind() != reflect.Func || v.IsNil() {
+		return nil, false
+	}
+
+	if _, ok := described.Load(v.Pointer()); !ok {
+		return nil, false
+	}
+
+	d := &describeContext{Context: context.Background()}
+
+	v.Call([]reflect.Value{reflect.ValueOf(d), reflect.Zero(v.Type().In(1))})
+
+	return d, true
+}
+
+// stageLabels returns labels of the handler, it is cheap for handlers without describe wrappers.
+func stageLabels(handler any) map[string]string {
+	if _, ok := query(handler); !ok {
+		return nil
+	}
+
+	return describeHandler(handler).Labels
+}
+
+// mergeLabels adds inner labels missing in labels.
+func mergeLabels(labels, inner map[string]string) map[string]string {
+	for k, v := range inner {
+		if _, ok := labels[k]; ok {
+			continue
+		}
+
+		if labels == nil {
+			labels = map[string]string{}
+		}
+
+		labels[k] = v
+	}
+
+	return labels
+}
+
+func handlerName(handler any) string {
+	v := reflect.ValueOf(handler)
+
+	switch {
+	case !v.IsValid() || (v.Kind() == reflect.Func && v.IsNil()):
+		return "<nil>"
+	case v.Kind() == reflect.Func:
+		return funcName(v.Pointer())
+	}
+
+	if s, ok := handler.(fmt.Stringer); ok {
+		return s.String()
+	}
+
+	return fmt.Sprintf("%T", handler)
+}
+
+// funcName returns name of the function without the package path.
+func funcName(pc uintptr) string {
+	f := runtime.FuncForPC(pc)
+	if f == nil {
+		return "<unknown>"
+	}
+
+	name := f.Name()
+
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+
+	return name
+}
@@ -0,0 +1,237 @@
+package pipe
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultJoinBuffer is the number of unmatched values JoinByKey keeps per side when no JoinBuffer option is given.
+const DefaultJoinBuffer = 1024
+
+// JoinOption configures JoinByKey.
+type JoinOption func(*joinConfig)
+
+type joinConfig struct {
+	buffer    int
+	timeout   time.Duration
+	unmatched func(v any)
+}
+
+// JoinBuffer limits the number of unmatched values kept per side, the oldest value is dropped when the limit is exceeded.
+func JoinBuffer(n int) JoinOption {
+	if n <= 0 {
+		panic("buffer value must be greater than zero!")
+	}
+
+	return func(c *joinConfig) {
+		c.buffer = n
+	}
+}
+
+// JoinTimeout drops values which have not been matched within d.
+func JoinTimeout(d time.Duration) JoinOption {
+	if d <= 0 {
+		panic("timeout value must be greater than zero!")
+	}
+
+	return func(c *joinConfig) {
+		c.timeout = d
+	}
+}
+
+// JoinUnmatched sets function receiving dropped values and values left unmatched when the source stops.
+func JoinUnmatched(fn func(v any)) JoinOption {
+	return func(c *joinConfig) {
+		c.unmatched = fn
+	}
+}
+
+// JoinByKey returns source emitting join(l, r) for values of left and right sources with equal keys.
+// Every value is joined at most once, values with the same key are matched in order of arrival.
+// The source stops at the first error of left or right.
+func JoinByKey[L, R, T any, K comparable](left Source[L], right Source[R], leftKey func(L) K, rightKey func(R) K, join func(l L, r R) T, opts ...JoinOption) Source[T] {
+	cfg := joinConfig{buffer: DefaultJoinBuffer}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	fn := func(ctx context.Context, out chan<- T) error {
+		ctx, cancel := context.WithCancelCause(ctx)
+
+		var wg sync.WaitGroup
+
+		defer func() {
+			cancel(nil)
+			wg.Wait()
+		}()
+
+		lvalues := make(chan L)
+		rvalues := make(chan R)
+
+		wg.Add(2)
+		spawn(ctx, func() { produce(ctx, &wg, cancel, left, lvalues) })
+		spawn(ctx, func() { produce(ctx, &wg, cancel, right, rvalues) })
+
+		lbuf := newJoinBuffer[K, L](cfg)
+		rbuf := newJoinBuffer[K, R](cfg)
+
+		defer lbuf.drop(cfg.unmatched)
+		defer rbuf.drop(cfg.unmatched)
+
+		var expire <-chan time.Time
+
+		if cfg.timeout > 0 {
+			ticker := time.NewTicker(cfg.timeout / 2)
+			defer ticker.Stop()
+
+			expire = ticker.C
+		}
+
+		emit := func(v T) error {
+			select {
+			case <-ctx.Done():
+				return cause(ctx)
+			case out <- v:
+				return nil
+			}
+		}
+
+		for lvalues != nil || rvalues != nil {
+			select {
+			case <-ctx.Done():
+				return cause(ctx)
+
+			case now := <-expire:
+				lbuf.expire(now, cfg.unmatched)
+				rbuf.expire(now, cfg.unmatched)
+
+			case l, ok := <-lvalues:
+				if !ok {
+					lvalues = nil
+					continue
+				}
+
+				k := leftKey(l)
+
+				if r, ok := rbuf.take(k); ok {
+					if err := emit(join(l, r)); err != nil {
+						return err
+					}
+				} else {
+					lbuf.put(k, l, cfg.unmatched)
+				}
+
+			case r, ok := <-rvalues:
+				if !ok {
+					rvalues = nil
+					continue
+				}
+
+				k := rightKey(r)
+
+				if l, ok := lbuf.take(k); ok {
+					if err := emit(join(l, r)); err != nil {
+						return err
+					}
+				} else {
+					rbuf.put(k, r, cfg.unmatched)
+				}
+			}
+		}
+
+		return cause(ctx)
+	}
+
+	return fn
+}
+
+// produce runs src closing out after it returns, error of src cancels ctx.
+func produce[T any](ctx context.Context, wg *sync.WaitGroup, cancel context.CancelCauseFunc, src Source[T], out chan T) {
+	defer wg.Done()
+	defer close(out)
+
+	if err := src(ctx, out); err != nil {
+		cancel(err)
+	}
+}
+
+// joinBuffer keeps unmatched values of one side of a join.
+type joinBuffer[K comparable, V any] struct {
+	limit   int
+	timeout time.Duration
+	order   *list.List
+	byKey   map[K][]*list.Element
+}
+
+type joinEntry[K comparable, V any] struct {
+	key K
+	v   V
+	at  time.Time
+}
+
+func newJoinBuffer[K comparable, V any](cfg joinConfig) *joinBuffer[K, V] {
+	return &joinBuffer[K, V]{
+		limit:   cfg.buffer,
+		timeout: cfg.timeout,
+		order:   list.New(),
+		byKey:   map[K][]*list.Element{},
+	}
+}
+
+func (b *joinBuffer[K, V]) put(k K, v V, unmatched func(any)) {
+	if b.order.Len() >= b.limit {
+		b.remove(b.order.Front(), unmatched)
+	}
+
+	e := b.order.PushBack(joinEntry[K, V]{key: k, v: v, at: time.Now()})
+	b.byKey[k] = append(b.byKey[k], e)
+}
+
+func (b *joinBuffer[K, V]) take(k K) (v V, ok bool) {
+	elems := b.byKey[k]
+	if len(elems) == 0 {
+		return v, false
+	}
+
+	e := elems[0]
+
+	if len(elems) == 1 {
+		delete(b.byKey, k)
+	} else {
+		b.byKey[k] = elems[1:]
+	}
+
+	b.order.Remove(e)
+
+	return e.Value.(joinEntry[K, V]).v, true
+}
+
+// remove drops the oldest entry e reporting it to unmatched.
+func (b *joinBuffer[K, V]) remove(e *list.Element, unmatched func(any)) {
+	entry := e.Value.(joinEntry[K, V])
+
+	b.take(entry.key)
+
+	if unmatched != nil {
+		unmatched(entry.v)
+	}
+}
+
+func (b *joinBuffer[K, V]) expire(now time.Time, unmatched func(any)) {
+	for e := b.order.Front(); e != nil; e = b.order.Front() {
+		if now.Sub(e.Value.(joinEntry[K, V]).at) < b.timeout {
+			return
+		}
+
+		b.remove(e, unmatched)
+	}
+}
+
+func (b *joinBuffer[K, V]) drop(unmatched func(any)) {
+	for e := b.order.Front(); e != nil; e = b.order.Front() {
+		b.remove(e, unmatched)
+	}
+}
@@ -0,0 +1,178 @@
+package pipe
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// checkLeaks returns function failing the test when routines started since the call have not exited
+// within a second, use it as defer checkLeaks(t)().
+func checkLeaks(t *testing.T) func() {
+	before := runtime.NumGoroutine()
+
+	return func() {
+		t.Helper()
+
+		deadline := time.Now().Add(time.Second)
+
+		for {
+			n := runtime.NumGoroutine()
+			if n <= before {
+				return
+			}
+
+			if time.Now().After(deadline) {
+				buf := make([]byte, 1<<20)
+				buf = buf[:runtime.Stack(buf, true)]
+
+				t.Fatalf("%d routines are left running:\n%s", n-before, buf)
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// endless is source emitting increasing numbers until ctx is done.
+func endless(ctx context.Context, out chan<- int) error {
+	for i := 0; ; i++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case out <- i:
+		}
+	}
+}
+
+// blocking is handler waiting for ctx to be done.
+func blocking[T any](ctx context.Context, in T) (out T, err error) {
+	<-ctx.Done()
+	return out, ctx.Err()
+}
+
+func TestStreamCancelLeak(t *testing.T) {
+	defer checkLeaks(t)()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	slow := func(ctx context.Context, in int) (int, error) {
+		if in%10 == 9 {
+			return blocking(ctx, in)
+		}
+
+		return in, nil
+	}
+
+	s := NewStream[int]().
+		Via(slow, Workers(4), Buffer(8)).
+		Via(func(ctx context.Context, in int) (int, error) { return in, nil }, ScaleWorkers(1, 4))
+
+	if err := s.Run(ctx, endless, func(ctx context.Context, in int) error { return nil }); err == nil {
+		t.Fatal("got nil error, want error of cancellation")
+	}
+}
+
+func TestParallelCancelLeak(t *testing.T) {
+	defer checkLeaks(t)()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	in := make([]int, 100)
+
+	if _, err := Parallel(ctx, Pipeline[[]int]{blocking[[]int]}, in, 10, ChunkSize(1), MaxInFlight(4)); err == nil {
+		t.Fatal("got nil error, want *RunReport")
+	}
+}
+
+func TestPoolCloseLeak(t *testing.T) {
+	defer checkLeaks(t)()
+
+	inc := func(ctx context.Context, in int) (int, error) {
+		time.Sleep(time.Millisecond)
+		return in + 1, nil
+	}
+
+	p := NewPool(Pipeline[int]{inc}, PoolWorkers(1, 4), PoolQueue(4), ScaleInterval(time.Millisecond))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				if _, err := p.Submit(context.Background(), i); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJoinByKeyLeak(t *testing.T) {
+	defer checkLeaks(t)()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	key := func(v int) int { return v / 2 }
+	join := func(l, r int) int { return l + r }
+
+	src := JoinByKey(endless, endless, key, key, join, JoinBuffer(16))
+
+	if err := NewStream[int]().Run(ctx, src, func(ctx context.Context, in int) error { return nil }); err == nil {
+		t.Fatal("got nil error, want error of cancellation")
+	}
+}
+
+func TestTimeoutLeak(t *testing.T) {
+	defer checkLeaks(t)()
+
+	h := Timeout(blocking[int], 10*time.Millisecond)
+
+	if _, err := Execute(context.Background(), Pipeline[int]{h}, 1); err == nil {
+		t.Fatal("got nil error, want timeout")
+	}
+}
+
+func TestHedgeLeak(t *testing.T) {
+	defer checkLeaks(t)()
+
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+
+	// The last attempt succeeds, the earlier ones block until they are canceled.
+	h := func(ctx context.Context, in int) (int, error) {
+		mu.Lock()
+		calls++
+		last := calls == 4
+		mu.Unlock()
+
+		if last {
+			return in, nil
+		}
+
+		return blocking(ctx, in)
+	}
+
+	out, err := Execute(context.Background(), Pipeline[int]{Hedge(h, time.Millisecond, 3)}, 7)
+	if err != nil || out != 7 {
+		t.Fatalf("got %d, %v, want 7, nil", out, err)
+	}
+}
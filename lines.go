@@ -0,0 +1,119 @@
+package pipe
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+)
+
+// DefaultMaxLine is the maximum length of a line read by Lines when no MaxLine option is given.
+const DefaultMaxLine = 1 << 20
+
+// LinesOption configures Lines and LineBytes.
+type LinesOption func(*linesConfig)
+
+type linesConfig struct {
+	maxLine int
+	gzip    bool
+}
+
+// MaxLine sets the maximum length of a line, longer lines fail the source with bufio.ErrTooLong.
+func MaxLine(n int) LinesOption {
+	if n <= 0 {
+		panic("max line value must be greater than zero!")
+	}
+
+	return func(c *linesConfig) {
+		c.maxLine = n
+	}
+}
+
+// DetectGzip makes the source decompress the input if it starts with the gzip header.
+func DetectGzip() LinesOption {
+	return func(c *linesConfig) {
+		c.gzip = true
+	}
+}
+
+// Lines returns source emitting lines of r without line terminators.
+// The input is read incrementally, so memory use does not depend on its size.
+func Lines(r io.Reader, opts ...LinesOption) Source[string] {
+	cfg := newLinesConfig(opts)
+
+	fn := func(ctx context.Context, out chan<- string) error {
+		return scanLines(ctx, r, cfg, func(line []byte) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case out <- string(line):
+				return true
+			}
+		})
+	}
+
+	return fn
+}
+
+// LineBytes is Lines emitting every line as a freshly allocated []byte.
+func LineBytes(r io.Reader, opts ...LinesOption) Source[[]byte] {
+	cfg := newLinesConfig(opts)
+
+	fn := func(ctx context.Context, out chan<- []byte) error {
+		return scanLines(ctx, r, cfg, func(line []byte) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case out <- bytes.Clone(line):
+				return true
+			}
+		})
+	}
+
+	return fn
+}
+
+func newLinesConfig(opts []LinesOption) linesConfig {
+	cfg := linesConfig{maxLine: DefaultMaxLine}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return cfg
+}
+
+// scanLines passes lines of r to emit until it returns false.
+func scanLines(ctx context.Context, r io.Reader, cfg linesConfig, emit func(line []byte) bool) error {
+	br := bufio.NewReader(r)
+	r = br
+
+	if cfg.gzip {
+		header, err := br.Peek(2)
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if len(header) == 2 && header[0] == 0x1f && header[1] == 0x8b {
+			zr, err := gzip.NewReader(br)
+			if err != nil {
+				return err
+			}
+			defer zr.Close()
+
+			r = zr
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, min(bufio.MaxScanTokenSize, cfg.maxLine)), cfg.maxLine)
+
+	for scanner.Scan() {
+		if !emit(scanner.Bytes()) {
+			return cause(ctx)
+		}
+	}
+
+	return scanner.Err()
+}
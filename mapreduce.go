@@ -0,0 +1,172 @@
+package pipe
+
+import (
+	"context"
+)
+
+// HandlerFunc2 is a handler converting T into R.
+type HandlerFunc2[T, R any] func(ctx context.Context, in T) (out R, err error)
+
+// MapReduce distributes 'in' between jobs, every job maps its elements with mapFn and folds them with combine
+// into a partial result, partials are combined in input order at the end. combine must be associative, it is
+// never called concurrently for the same partial, so it may update its first argument in place.
+// The first failure cancels other jobs and is returned as *BatchError, a panic as *PanicError. Empty input gives zero R.
+func MapReduce[T, R any](ctx context.Context, mapFn HandlerFunc2[T, R], combine func(R, R) R, in []T, jobs int) (out R, err error) {
+	if jobs <= 0 {
+		panic("jobs value must be greater than zero!")
+	}
+
+	batches := split[T](len(in), jobs)
+	partials := make([]R, len(batches))
+
+	err = fanOut(ctx, len(batches), func(ctx context.Context, i int) (err error) {
+		b := batches[i]
+
+		partials[i], err = reduceBatch(ctx, mapFn, combine, in[b.Offset:b.Offset+b.Len])
+		if err != nil {
+			return &BatchError{Batch: b.Index, Offset: b.Offset, Len: b.Len, Stage: -1, Err: err}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return out, err
+	}
+
+	for i, p := range partials {
+		if i == 0 {
+			out = p
+			continue
+		}
+
+		out = combine(out, p)
+	}
+
+	return out, nil
+}
+
+// reduceBatch maps and folds elements of a single batch.
+func reduceBatch[T, R any](ctx context.Context, mapFn HandlerFunc2[T, R], combine func(R, R) R, in []T) (out R, err error) {
+	for i, v := range in {
+		if err := CheckCtx(ctx); err != nil {
+			return out, err
+		}
+
+		r, err := mapFn(ctx, v)
+		if err != nil {
+			return out, err
+		}
+
+		if i == 0 {
+			out = r
+			continue
+		}
+
+		out = combine(out, r)
+	}
+
+	return out, nil
+}
+
+// ParallelReduce folds 'in' with associative op: jobs fold parts of the input concurrently, then partial results
+// are combined pairwise level by level, like a tree. Empty input gives zero T. It fails only when ctx is done
+// or op panics.
+func ParallelReduce[T any](ctx context.Context, op func(T, T) T, in []T, jobs int) (out T, err error) {
+	if jobs <= 0 {
+		panic("jobs value must be greater than zero!")
+	}
+
+	if len(in) == 0 {
+		return out, nil
+	}
+
+	identity := func(ctx context.Context, v T) (T, error) {
+		return v, nil
+	}
+
+	batches := split[T](len(in), jobs)
+	partials := make([]T, len(batches))
+
+	err = fanOut(ctx, len(batches), func(ctx context.Context, i int) (err error) {
+		b := batches[i]
+		partials[i], err = reduceBatch(ctx, identity, op, in[b.Offset:b.Offset+b.Len])
+
+		return err
+	})
+	if err != nil {
+		return out, err
+	}
+
+	for len(partials) > 1 {
+		level := partials
+		partials = make([]T, (len(level)+1)/2)
+
+		err = fanOut(ctx, len(level)/2, func(ctx context.Context, i int) error {
+			partials[i] = op(level[2*i], level[2*i+1])
+			return nil
+		})
+		if err != nil {
+			return out, err
+		}
+
+		if len(level)%2 == 1 {
+			partials[len(partials)-1] = level[len(level)-1]
+		}
+	}
+
+	out = partials[0]
+
+	return out, nil
+}
+
+// fanOut calls fn for 0..n-1 in separate routines and returns the first failure, which cancels the others.
+func fanOut(ctx context.Context, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	done := make(chan error, n)
+
+	for i := 0; i < n; i++ {
+		i := i
+
+		spawn(ctx, func() {
+			var err error
+
+			defer func() {
+				if err != nil {
+					cancel(err)
+				}
+
+				done <- err
+			}()
+
+			defer func() {
+				if !recovering(ctx) {
+					return
+				}
+
+				if rec := recover(); rec != nil {
+					err = newPanicError(rec)
+				}
+			}()
+
+			err = fn(ctx, i)
+		})
+	}
+
+	var first error
+
+	for i := 0; i < n; i++ {
+		if err := <-done; err != nil && first == nil {
+			first = err
+		}
+	}
+
+	if first != nil {
+		if c := context.Cause(ctx); c != nil && c != context.Canceled {
+			return c
+		}
+	}
+
+	return first
+}
@@ -0,0 +1,114 @@
+package pipe
+
+import (
+	"context"
+	"sync"
+)
+
+// Metadata is a bag of values attached to a run of Execute or to an element of a stream,
+// so stages can pass values along without changing T. Metadata is safe for concurrent use.
+type Metadata struct {
+	mu     sync.RWMutex
+	keys   []metaName
+	values map[any]any
+}
+
+type metaName interface {
+	metaName() string
+}
+
+// MetaKey is a typed key of Metadata values.
+type MetaKey[V any] struct {
+	name string
+}
+
+// NewMetaKey returns key of values named name in snapshots. Keys are compared by identity.
+func NewMetaKey[V any](name string) *MetaKey[V] {
+	return &MetaKey[V]{name: name}
+}
+
+func (k *MetaKey[V]) metaName() string {
+	return k.name
+}
+
+// Name returns name of the key.
+func (k *MetaKey[V]) Name() string {
+	return k.name
+}
+
+// Get returns value of the key from metadata of ctx.
+func (k *MetaKey[V]) Get(ctx context.Context) (value V, ok bool) {
+	md := MetadataFrom(ctx)
+	if md == nil {
+		return value, false
+	}
+
+	md.mu.RLock()
+	defer md.mu.RUnlock()
+
+	value, ok = md.values[k].(V)
+
+	return value, ok
+}
+
+// Set stores value of the key into metadata of ctx. It does nothing when ctx has no metadata.
+func (k *MetaKey[V]) Set(ctx context.Context, value V) {
+	md := MetadataFrom(ctx)
+	if md == nil {
+		return
+	}
+
+	md.mu.Lock()
+	defer md.mu.Unlock()
+
+	if md.values == nil {
+		md.values = map[any]any{}
+	}
+
+	if _, ok := md.values[k]; !ok {
+		md.keys = append(md.keys, k)
+	}
+
+	md.values[k] = value
+}
+
+// Snapshot returns copy of values by names of their keys.
+func (md *Metadata) Snapshot() map[string]any {
+	if md == nil {
+		return nil
+	}
+
+	md.mu.RLock()
+	defer md.mu.RUnlock()
+
+	if len(md.keys) == 0 {
+		return nil
+	}
+
+	snapshot := make(map[string]any, len(md.keys))
+
+	for _, k := range md.keys {
+		snapshot[k.metaName()] = md.values[k]
+	}
+
+	return snapshot
+}
+
+type metadataKey struct{}
+
+// WithMetadata returns ctx carrying empty metadata. Execute called with such ctx shares the metadata
+// with the caller instead of creating its own.
+func WithMetadata(ctx context.Context) (context.Context, *Metadata) {
+	md := &Metadata{}
+	return context.WithValue(ctx, metadataKey{}, md), md
+}
+
+// MetadataFrom returns metadata of the current run or stream element, nil if there is none.
+func MetadataFrom(ctx context.Context) *Metadata {
+	md, _ := ctx.Value(metadataKey{}).(*Metadata)
+	return md
+}
+
+func withMetadata(ctx context.Context, md *Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
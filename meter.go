@@ -0,0 +1,164 @@
+package pipe
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMeterWindow is the period of rolling throughput when no window is given to NewMeter.
+const DefaultMeterWindow = 10 * time.Second
+
+// meterBuckets is the number of buckets of the rolling window.
+const meterBuckets = 10
+
+// Meter measures throughput of stages from stage events, attach its Hook with WithHook.
+type Meter struct {
+	mu     sync.Mutex
+	window time.Duration
+	total  int
+	start  time.Time
+	stages map[int]*stageMeter
+}
+
+type stageMeter struct {
+	count   int
+	failed  int
+	buckets [meterBuckets]meterBucket
+}
+
+type meterBucket struct {
+	start time.Time
+	count int
+}
+
+// StageThroughput is throughput of a stage.
+type StageThroughput struct {
+	Stage int
+	// Count is the number of values the stage has handled, Failed is the number of its failures.
+	Count  int
+	Failed int
+	// Rate is the number of values handled per second within the rolling window.
+	Rate float64
+}
+
+// MeterStats is a snapshot of Meter.
+type MeterStats struct {
+	Elapsed time.Duration
+	Stages  []StageThroughput
+	// ETA is the estimated time until the last stage has handled the total number of values, it is zero
+	// when the input is unbounded, the last stage has not handled anything within the window or the job is done.
+	ETA time.Duration
+}
+
+// NewMeter returns meter with rolling window, total is the number of input values or zero if it is unbounded.
+// Zero window means DefaultMeterWindow, otherwise it must be at least 10ns to split it into buckets.
+func NewMeter(window time.Duration, total int) *Meter {
+	if window < 0 {
+		panic("window value must not be negative!")
+	}
+
+	if window > 0 && window < meterBuckets {
+		panic("window value must be at least 10ns!")
+	}
+
+	if window == 0 {
+		window = DefaultMeterWindow
+	}
+
+	return &Meter{
+		window: window,
+		total:  total,
+		start:  time.Now(),
+		stages: map[int]*stageMeter{},
+	}
+}
+
+// Hook returns hook counting EventStageDone events.
+func (m *Meter) Hook() Hook {
+	fn := func(ctx context.Context, e Event) {
+		if e.Kind != EventStageDone {
+			return
+		}
+
+		m.observe(e.Stage, e.Time, e.Err != nil)
+	}
+
+	return fn
+}
+
+func (m *Meter) observe(stage int, now time.Time, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.stages[stage]
+	if !ok {
+		s = &stageMeter{}
+		m.stages[stage] = s
+	}
+
+	s.count++
+
+	if failed {
+		s.failed++
+	}
+
+	width := m.window / meterBuckets
+	start := now.Truncate(width)
+	b := &s.buckets[int(start.UnixNano()/int64(width))%meterBuckets]
+
+	if !b.start.Equal(start) {
+		*b = meterBucket{start: start}
+	}
+
+	b.count++
+}
+
+// Stats returns throughput of stages ordered by stage index.
+func (m *Meter) Stats() MeterStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	stats := MeterStats{Elapsed: now.Sub(m.start)}
+
+	for stage, s := range m.stages {
+		stats.Stages = append(stats.Stages, StageThroughput{
+			Stage:  stage,
+			Count:  s.count,
+			Failed: s.failed,
+			Rate:   m.rate(s, now),
+		})
+	}
+
+	sort.Slice(stats.Stages, func(i, j int) bool {
+		return stats.Stages[i].Stage < stats.Stages[j].Stage
+	})
+
+	if n := len(stats.Stages); n > 0 && m.total > 0 {
+		last := stats.Stages[n-1]
+		stats.ETA = eta(m.total-last.Count, last.Rate)
+	}
+
+	return stats
+}
+
+// rate returns values per second counted by buckets within the window.
+func (m *Meter) rate(s *stageMeter, now time.Time) float64 {
+	count := 0
+
+	for _, b := range s.buckets {
+		if now.Sub(b.start) < m.window {
+			count += b.count
+		}
+	}
+
+	window := m.window
+	if elapsed := now.Sub(m.start); elapsed < window {
+		window = elapsed
+	}
+
+	return rate(count, window)
+}
@@ -0,0 +1,44 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewMeterWindow(t *testing.T) {
+	tests := []struct {
+		window time.Duration
+		panics bool
+	}{
+		{window: -1, panics: true},
+		{window: 0},
+		{window: 1, panics: true},
+		{window: meterBuckets - 1, panics: true},
+		{window: meterBuckets},
+		{window: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.window.String(), func(t *testing.T) {
+			defer func() {
+				if r := recover(); (r != nil) != tt.panics {
+					t.Fatalf("got panic %v, want panic %t", r, tt.panics)
+				}
+			}()
+
+			m := NewMeter(tt.window, 0)
+			hook := m.Hook()
+
+			hook(context.Background(), Event{Kind: EventStageDone, Time: time.Now()})
+			hook(context.Background(), Event{Kind: EventStageDone, Time: time.Now(), Err: errors.New("broken")})
+
+			stats := m.Stats()
+
+			if len(stats.Stages) != 1 || stats.Stages[0].Count != 2 || stats.Stages[0].Failed != 1 {
+				t.Fatalf("got stats %+v, want 2 values of stage 0 with 1 failure", stats.Stages)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TargetOption configures a target of MultiSink.
+type TargetOption func(*targetConfig)
+
+type targetConfig struct {
+	name     string
+	attempts int
+	retry    []RetryOption
+	optional bool
+	onError  func(err error)
+	timeout  time.Duration
+}
+
+// TargetName sets name of the target used in its errors.
+func TargetName(name string) TargetOption {
+	return func(c *targetConfig) {
+		c.name = name
+	}
+}
+
+// TargetRetry makes the target retry failed writes up to attempts times in total.
+func TargetRetry(attempts int, opts ...RetryOption) TargetOption {
+	if attempts <= 0 {
+		panic("attempts value must be greater than zero!")
+	}
+
+	return func(c *targetConfig) {
+		c.attempts = attempts
+		c.retry = opts
+	}
+}
+
+// TargetOptional makes failures of the target not fail MultiSink, they are passed to onError instead if it is not nil.
+func TargetOptional(onError func(err error)) TargetOption {
+	return func(c *targetConfig) {
+		c.optional = true
+		c.onError = onError
+	}
+}
+
+// TargetTimeout bounds every write to the target including its retries by d, a slow target then fails
+// with context.DeadlineExceeded instead of holding the other targets back. The sink must respect ctx.
+// Combine it with TargetOptional to let the others go on without a lagging target.
+func TargetTimeout(d time.Duration) TargetOption {
+	if d <= 0 {
+		panic("timeout value must be greater than zero!")
+	}
+
+	return func(c *targetConfig) {
+		c.timeout = d
+	}
+}
+
+// Target is a sink of MultiSink with its own error policy.
+type Target[T any] struct {
+	sink Sink[T]
+	cfg  targetConfig
+}
+
+// NewTarget returns target writing to sink.
+func NewTarget[T any](sink Sink[T], opts ...TargetOption) Target[T] {
+	cfg := targetConfig{attempts: 1}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	t := Target[T]{sink: sink, cfg: cfg}
+
+	if cfg.attempts > 1 {
+		write := Retry(func(ctx context.Context, in T) (T, error) {
+			return in, sink(ctx, in)
+		}, cfg.attempts, cfg.retry...)
+
+		t.sink = func(ctx context.Context, in T) error {
+			_, err := write(ctx, in)
+			return err
+		}
+	}
+
+	if cfg.timeout > 0 {
+		write := t.sink
+
+		t.sink = func(ctx context.Context, in T) error {
+			ctx, cancel := context.WithTimeout(ctx, cfg.timeout)
+			defer cancel()
+
+			return write(ctx, in)
+		}
+	}
+
+	return t
+}
+
+// TargetError is an error of a MultiSink target.
+type TargetError struct {
+	// Target is the index of the target given to MultiSink.
+	Target int
+	// Name is the name set by TargetName.
+	Name string
+	Err  error
+}
+
+func (e *TargetError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("pipeline: target %s: %s", e.Name, e.Err)
+	}
+
+	return fmt.Sprintf("pipeline: target %d: %s", e.Target, e.Err)
+}
+
+func (e *TargetError) Unwrap() error {
+	return e.Err
+}
+
+// MultiSink returns sink writing every value to all targets concurrently and waiting for them,
+// so by default the slowest target sets the pace of all of them, bound it with TargetTimeout.
+// A failed target does not stop the others. Failures of required targets are joined and returned,
+// every one is *TargetError.
+func MultiSink[T any](targets ...Target[T]) Sink[T] {
+	fn := func(ctx context.Context, in T) error {
+		errs := make([]error, len(targets))
+
+		var wg sync.WaitGroup
+
+		for i, t := range targets {
+			i, t := i, t
+
+			wg.Add(1)
+			spawn(ctx, func() {
+				defer wg.Done()
+
+				err := t.sink(ctx, in)
+				if err == nil {
+					return
+				}
+
+				err = &TargetError{Target: i, Name: t.cfg.name, Err: err}
+
+				if !t.cfg.optional {
+					errs[i] = err
+				} else if t.cfg.onError != nil {
+					t.cfg.onError(err)
+				}
+			})
+		}
+
+		wg.Wait()
+
+		return errors.Join(errs...)
+	}
+
+	return fn
+}
@@ -0,0 +1,120 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMultiSink(t *testing.T) {
+	defer checkLeaks(t)()
+
+	errBroken := errors.New("broken")
+
+	var written atomic.Int32
+
+	fast := func(ctx context.Context, in int) error {
+		written.Add(1)
+		return nil
+	}
+
+	slow := func(ctx context.Context, in int) error {
+		_, err := blocking(ctx, in)
+		return err
+	}
+
+	broken := func(ctx context.Context, in int) error { return errBroken }
+
+	var optional atomic.Pointer[error]
+
+	onError := func(err error) { optional.Store(&err) }
+
+	tests := []struct {
+		name     string
+		targets  []Target[int]
+		err      error
+		optional error
+		target   int
+		written  int32
+	}{
+		{
+			name:    "success",
+			targets: []Target[int]{NewTarget[int](fast), NewTarget[int](fast)},
+			written: 2,
+		},
+		{
+			name:    "required failure",
+			targets: []Target[int]{NewTarget[int](fast), NewTarget[int](broken, TargetName("broken"))},
+			err:     errBroken,
+			target:  1,
+			written: 1,
+		},
+		{
+			name:     "optional failure",
+			targets:  []Target[int]{NewTarget[int](broken, TargetOptional(onError)), NewTarget[int](fast)},
+			optional: errBroken,
+			written:  1,
+		},
+		{
+			name:    "timeout",
+			targets: []Target[int]{NewTarget[int](fast), NewTarget[int](slow, TargetTimeout(10*time.Millisecond))},
+			err:     context.DeadlineExceeded,
+			target:  1,
+			written: 1,
+		},
+		{
+			name:     "optional timeout",
+			targets:  []Target[int]{NewTarget[int](slow, TargetTimeout(10*time.Millisecond), TargetOptional(onError)), NewTarget[int](fast)},
+			optional: context.DeadlineExceeded,
+			written:  1,
+		},
+		{
+			name:    "retried timeout",
+			targets: []Target[int]{NewTarget[int](slow, TargetTimeout(10*time.Millisecond), TargetRetry(3, RetryDelay(time.Hour)))},
+			err:     context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			written.Store(0)
+			optional.Store(nil)
+
+			start := time.Now()
+
+			err := MultiSink(tt.targets...)(context.Background(), 1)
+
+			if d := time.Since(start); d > time.Second {
+				t.Fatalf("the write has taken %s", d)
+			}
+
+			if tt.err == nil && err != nil {
+				t.Fatalf("got error %v, want nil", err)
+			}
+
+			if tt.err != nil {
+				var te *TargetError
+				if !errors.Is(err, tt.err) || !errors.As(err, &te) || te.Target != tt.target {
+					t.Fatalf("got error %v, want %v of target %d", err, tt.err, tt.target)
+				}
+			}
+
+			if tt.optional != nil {
+				var err error
+				if p := optional.Load(); p != nil {
+					err = *p
+				}
+
+				if !errors.Is(err, tt.optional) {
+					t.Fatalf("got optional error %v, want %v", err, tt.optional)
+				}
+			}
+
+			if n := written.Load(); n != tt.written {
+				t.Fatalf("got %d writes, want %d", n, tt.written)
+			}
+		})
+	}
+}
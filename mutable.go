@@ -0,0 +1,120 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrStageIndex is returned by MutablePipeline when a stage index is out of range.
+var ErrStageIndex = errors.New("pipeline: stage index out of range")
+
+// MutablePipeline is a pipeline which stages may be changed while it is executed.
+// Changes are copy-on-write: every run executes a snapshot taken when it has started,
+// so in-flight runs are not affected.
+type MutablePipeline[T any] struct {
+	mu       sync.Mutex
+	pipeline atomic.Pointer[Pipeline[T]]
+}
+
+// NewMutablePipeline returns mutable pipeline starting with a copy of pipeline.
+func NewMutablePipeline[T any](pipeline Pipeline[T]) *MutablePipeline[T] {
+	m := &MutablePipeline[T]{}
+
+	p := append(Pipeline[T](nil), pipeline...)
+	m.pipeline.Store(&p)
+
+	return m
+}
+
+// Snapshot returns current stages, the returned pipeline must not be modified.
+func (m *MutablePipeline[T]) Snapshot() Pipeline[T] {
+	return *m.pipeline.Load()
+}
+
+// Execute executes the current snapshot of the pipeline.
+func (m *MutablePipeline[T]) Execute(ctx context.Context, in T) (out T, err error) {
+	return Execute(ctx, m.Snapshot(), in)
+}
+
+// Handler returns handler executing the current snapshot of the pipeline for every call.
+func (m *MutablePipeline[T]) Handler() HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		return m.Execute(ctx, in)
+	}
+
+	return describe(fn, describeInfo{middleware: "Mutable", inner: m})
+}
+
+func (m *MutablePipeline[T]) String() string {
+	return m.Snapshot().String()
+}
+
+// Set replaces all stages.
+func (m *MutablePipeline[T]) Set(pipeline Pipeline[T]) {
+	m.update(func(Pipeline[T]) (Pipeline[T], error) {
+		return append(Pipeline[T](nil), pipeline...), nil
+	})
+}
+
+// Append adds stages to the end of the pipeline.
+func (m *MutablePipeline[T]) Append(handlers ...HandlerFunc[T]) {
+	m.update(func(p Pipeline[T]) (Pipeline[T], error) {
+		return append(p, handlers...), nil
+	})
+}
+
+// Insert inserts handler before the i-th stage, i equal to the number of stages appends it.
+func (m *MutablePipeline[T]) Insert(i int, handler HandlerFunc[T]) error {
+	return m.update(func(p Pipeline[T]) (Pipeline[T], error) {
+		if i < 0 || i > len(p) {
+			return nil, ErrStageIndex
+		}
+
+		p = append(p, nil)
+		copy(p[i+1:], p[i:])
+		p[i] = handler
+
+		return p, nil
+	})
+}
+
+// Remove removes the i-th stage.
+func (m *MutablePipeline[T]) Remove(i int) error {
+	return m.update(func(p Pipeline[T]) (Pipeline[T], error) {
+		if i < 0 || i >= len(p) {
+			return nil, ErrStageIndex
+		}
+
+		return append(p[:i], p[i+1:]...), nil
+	})
+}
+
+// Replace replaces the i-th stage with handler.
+func (m *MutablePipeline[T]) Replace(i int, handler HandlerFunc[T]) error {
+	return m.update(func(p Pipeline[T]) (Pipeline[T], error) {
+		if i < 0 || i >= len(p) {
+			return nil, ErrStageIndex
+		}
+
+		p[i] = handler
+
+		return p, nil
+	})
+}
+
+// update applies change to a copy of the current stages and publishes the result.
+func (m *MutablePipeline[T]) update(change func(p Pipeline[T]) (Pipeline[T], error)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := change(append(Pipeline[T](nil), *m.pipeline.Load()...))
+	if err != nil {
+		return err
+	}
+
+	m.pipeline.Store(&p)
+
+	return nil
+}
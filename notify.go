@@ -0,0 +1,180 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"text/template"
+)
+
+// Notification is a rendered message for people.
+type Notification struct {
+	Subject string
+	Body    string
+}
+
+// Notifier delivers notifications, e.g. by email or to a chat.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NotifierFunc is a func implementing Notifier.
+type NotifierFunc func(ctx context.Context, n Notification) error
+
+func (f NotifierFunc) Notify(ctx context.Context, n Notification) error {
+	return f(ctx, n)
+}
+
+// RenderFunc renders a value as notification.
+type RenderFunc[T any] func(v T) (Notification, error)
+
+// NotifyTemplate returns render executing text templates of subject and body with the value as data.
+func NotifyTemplate[T any](subject, body string) (RenderFunc[T], error) {
+	st, err := template.New("subject").Parse(subject)
+	if err != nil {
+		return nil, err
+	}
+
+	bt, err := template.New("body").Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	fn := func(v T) (n Notification, err error) {
+		var buf strings.Builder
+
+		if err := st.Execute(&buf, v); err != nil {
+			return n, err
+		}
+
+		n.Subject = buf.String()
+		buf.Reset()
+
+		if err := bt.Execute(&buf, v); err != nil {
+			return n, err
+		}
+
+		n.Body = buf.String()
+
+		return n, nil
+	}
+
+	return fn, nil
+}
+
+// NotifyFormat returns render of notifications with constant subject and body formatted by format.
+func NotifyFormat[T any](subject string, format Formatter[T]) RenderFunc[T] {
+	fn := func(v T) (Notification, error) {
+		return Notification{Subject: subject, Body: format(v)}, nil
+	}
+
+	return fn
+}
+
+// NotifySink returns sink rendering every value with render and delivering it to all notifiers.
+// A failed notifier does not stop the others, their errors are joined.
+func NotifySink[T any](render RenderFunc[T], notifiers ...Notifier) Sink[T] {
+	fn := func(ctx context.Context, in T) error {
+		n, err := render(in)
+		if err != nil {
+			return err
+		}
+
+		var errs []error
+
+		for _, notifier := range notifiers {
+			if err := notifier.Notify(ctx, n); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		return errors.Join(errs...)
+	}
+
+	return fn
+}
+
+// SlackNotifier posts notifications to a Slack incoming webhook at URL, the subject is put in bold before the body.
+type SlackNotifier struct {
+	URL string
+	// Client is used for requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+func (s SlackNotifier) Notify(ctx context.Context, n Notification) error {
+	text := n.Body
+	if n.Subject != "" {
+		text = "*" + n.Subject + "*\n" + n.Body
+	}
+
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pipeline: slack: %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// SMTPNotifier sends notifications as plain text emails with smtp.SendMail.
+// It does not respect cancellation of ctx.
+type SMTPNotifier struct {
+	// Addr is host:port of the server.
+	Addr string
+	// Auth is used if it is not nil.
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+// Notify sends the notification. Line breaks of the subject are replaced by spaces,
+// addresses containing line breaks are rejected.
+func (s SMTPNotifier) Notify(ctx context.Context, n Notification) error {
+	to := strings.Join(s.To, ", ")
+
+	if strings.ContainsAny(s.From, "\r\n") || strings.ContainsAny(to, "\r\n") {
+		return errors.New("pipeline: smtp: address contains line break")
+	}
+
+	subject := strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(n.Subject)
+
+	var msg bytes.Buffer
+
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(n.Body, "\n", "\r\n"))
+
+	return smtp.SendMail(s.Addr, s.Auth, s.From, s.To, msg.Bytes())
+}
@@ -0,0 +1,60 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrEvent describes a failure of a stage for an error pipeline, see OnError.
+type ErrEvent[T any] struct {
+	// In is the value which has entered the failed stage.
+	In T
+	// Stage is the index of the failed stage, Labels are its labels.
+	Stage  int
+	Labels map[string]string
+	// Err is the error of the stage, panics are given as *PanicError.
+	Err error
+	// Metadata is the metadata of the failed run.
+	Metadata *Metadata
+}
+
+// OnError returns copy of pipeline which stages execute errPipeline when they fail, so compensation,
+// alerting and dead-lettering are done in one place instead of in every caller.
+// The error pipeline runs with ctx detached from cancellation, its failure is joined to the error of the stage.
+// The stage error is returned as usual. ErrSkipRest, ErrStop and ErrDropped are not failures.
+func OnError[T any](pipeline Pipeline[T], errPipeline Pipeline[ErrEvent[T]]) Pipeline[T] {
+	p := make(Pipeline[T], len(pipeline))
+
+	for i, handler := range pipeline {
+		p[i] = onError(i, handler, errPipeline)
+	}
+
+	return p
+}
+
+func onError[T any](stage int, handler HandlerFunc[T], errPipeline Pipeline[ErrEvent[T]]) HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		out, err = call(ctx, handler, in)
+		if err == nil || finished(err) || errors.Is(err, ErrDropped) {
+			return out, err
+		}
+
+		ev := ErrEvent[T]{
+			In:       in,
+			Stage:    stage,
+			Labels:   stageLabels(handler),
+			Err:      err,
+			Metadata: MetadataFrom(ctx),
+		}
+
+		if _, perr := Execute(context.WithoutCancel(ctx), errPipeline, ev); perr != nil {
+			return out, errors.Join(err, perr)
+		}
+
+		return out, err
+	}
+
+	clone := func() any { return onError(stage, cloneHandler(handler), errPipeline.Clone()) }
+
+	return describe(fn, describeInfo{middleware: "OnError", inner: handler, clone: clone})
+}
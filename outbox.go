@@ -0,0 +1,86 @@
+package pipe
+
+import (
+	"context"
+	"sync"
+)
+
+// Outbox is a transactional store of pipeline outputs, e.g. a table next to the business data.
+// A separate relay publishes committed outputs downstream.
+type Outbox[T any] interface {
+	// Commit atomically appends values and advances the watermark.
+	// Implementations should check inside the transaction that watermark is greater than the committed one.
+	Commit(ctx context.Context, values []T, watermark int64) error
+	// Watermark returns the last committed watermark, it is zero before the first commit.
+	Watermark(ctx context.Context) (int64, error)
+}
+
+// OutboxSink returns terminal stage committing every batch into outbox together with its watermark,
+// e.g. the offset of the last element in the source. Batches which watermark is not greater
+// than the committed one are skipped, so replaying the source after a crash does not commit outputs twice.
+// Batches are passed further unchanged.
+func OutboxSink[T any](outbox Outbox[T], watermark func(batch []T) int64) HandlerFunc[[]T] {
+	fn := func(ctx context.Context, in []T) (out []T, err error) {
+		if len(in) == 0 {
+			return in, nil
+		}
+
+		mark := watermark(in)
+
+		committed, err := outbox.Watermark(ctx)
+		if err != nil {
+			return out, err
+		}
+
+		if mark <= committed {
+			return in, nil
+		}
+
+		if err := outbox.Commit(ctx, in, mark); err != nil {
+			return out, err
+		}
+
+		return in, nil
+	}
+
+	return fn
+}
+
+// MemoryOutbox is Outbox keeping values in memory. Zero value is ready to use.
+type MemoryOutbox[T any] struct {
+	mu        sync.Mutex
+	values    []T
+	watermark int64
+}
+
+func (o *MemoryOutbox[T]) Commit(ctx context.Context, values []T, watermark int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if watermark <= o.watermark {
+		return nil
+	}
+
+	o.values = append(o.values, values...)
+	o.watermark = watermark
+
+	return nil
+}
+
+func (o *MemoryOutbox[T]) Watermark(ctx context.Context) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.watermark, nil
+}
+
+// Take removes and returns committed values.
+func (o *MemoryOutbox[T]) Take() []T {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	values := o.values
+	o.values = nil
+
+	return values
+}
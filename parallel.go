@@ -0,0 +1,149 @@
+package pipe
+
+import (
+	"runtime"
+	"runtime/metrics"
+)
+
+// ParallelOption configures Parallel.
+type ParallelOption func(*parallelConfig)
+
+type parallelConfig struct {
+	memoryLimit uint64
+	checkpoint  *checkpoint
+	onProgress  func(Progress)
+	unordered   bool
+	chunkSize   int
+	maxInFlight int
+	failFast    bool
+	lockThread  bool
+	governor    *Governor
+	clone       any
+}
+
+func newParallelConfig(opts []ParallelOption) parallelConfig {
+	var c parallelConfig
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// MemoryLimit makes Parallel hold off starting new batches while heap objects take more than limit bytes,
+// it waits for running batches to finish instead. At least one batch is always running, so Parallel
+// keeps making progress under pressure.
+func MemoryLimit(limit uint64) ParallelOption {
+	if limit == 0 {
+		panic("limit value must be greater than zero!")
+	}
+
+	return func(c *parallelConfig) {
+		c.memoryLimit = limit
+	}
+}
+
+// OnProgress sets function called by Parallel after every batch has completed.
+// It is called from the routine of Parallel, so it may update a terminal progress bar without locking.
+func OnProgress(fn func(p Progress)) ParallelOption {
+	return func(c *parallelConfig) {
+		c.onProgress = fn
+	}
+}
+
+// Unordered makes Parallel concatenate results of batches in order of their completion instead of input order.
+func Unordered() ParallelOption {
+	return func(c *parallelConfig) {
+		c.unordered = true
+	}
+}
+
+// ChunkSize sets number of input elements of a batch, by default the input is split into jobs equal batches.
+// Batches are run by at most jobs routines at once unless MaxInFlight is given.
+func ChunkSize(n int) ParallelOption {
+	if n <= 0 {
+		panic("chunk size value must be greater than zero!")
+	}
+
+	return func(c *parallelConfig) {
+		c.chunkSize = n
+	}
+}
+
+// MaxInFlight limits number of concurrently running batches, it is jobs by default.
+func MaxInFlight(n int) ParallelOption {
+	if n <= 0 {
+		panic("max in-flight value must be greater than zero!")
+	}
+
+	return func(c *parallelConfig) {
+		c.maxInFlight = n
+	}
+}
+
+// FailFast makes the first failed batch cancel running batches, batches which have not started yet
+// are not run and fail with the cause of cancellation. By default all batches are run regardless of failures.
+func FailFast() ParallelOption {
+	return func(c *parallelConfig) {
+		c.failFast = true
+	}
+}
+
+// LockOSThread makes every batch run on a routine locked to its OS thread, see runtime.LockOSThread.
+// Use it for handlers wrapping cgo libraries with thread affinity requirements,
+// MaxInFlight limits the number of threads occupied at once.
+func LockOSThread() ParallelOption {
+	return func(c *parallelConfig) {
+		c.lockThread = true
+	}
+}
+
+// Govern makes governor limit the number of batches executed at once.
+func Govern(g *Governor) ParallelOption {
+	return func(c *parallelConfig) {
+		c.governor = g
+	}
+}
+
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// lock locks the calling routine to its thread if it is required and returns function unlocking it.
+func (c *parallelConfig) lock() (unlock func()) {
+	if !c.lockThread {
+		return func() {}
+	}
+
+	runtime.LockOSThread()
+
+	return runtime.UnlockOSThread
+}
+
+// limit returns the allowed part of limit concurrent batches.
+func (c *parallelConfig) limit(limit int) int {
+	if c.governor == nil {
+		return limit
+	}
+
+	return c.governor.Limit(limit)
+}
+
+func (c *parallelConfig) overMemoryLimit() bool {
+	if c.memoryLimit == 0 {
+		return false
+	}
+
+	return heapObjects() > c.memoryLimit
+}
+
+// heapObjects returns memory occupied by live and not yet swept heap objects.
+func heapObjects() uint64 {
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+
+	return sample[0].Value.Uint64()
+}
@@ -2,33 +2,120 @@ package pipe
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"runtime/debug"
-	"sync"
 )
 
 type HandlerFunc[T any] func(ctx context.Context, in T) (out T, err error)
 
+// ErrSkipRest is returned by a handler with its result to finish processing of the value successfully,
+// remaining stages are skipped. It may be wrapped. Execute returns the result with nil error,
+// Stream passes it to the sink, ForEach keeps it as the result of the element.
+var ErrSkipRest = errors.New("pipeline: skip rest of stages")
+
+// ErrStop is returned by a handler with its result to finish the whole run successfully, like when the answer
+// has been found. It may be wrapped. Execute returns the result with nil error, a nested run passes ErrStop
+// to its enclosing one. ForEach returns it leaving remaining elements unprocessed. Stream passes the value
+// to the sink and stops, Run returns nil.
+var ErrStop = errors.New("pipeline: stop run")
+
+// ErrDropped is returned by a handler to drop the value. It may be wrapped. Stream does not pass the value
+// to the sink and keeps running, ForEach removes the element from its result, Execute returns it as *StageError.
+var ErrDropped = errors.New("pipeline: value dropped")
+
+// errRunStopped cancels running batches of Parallel after one of them has returned ErrStop.
+var errRunStopped = errors.New("pipeline: run stopped")
+
+// finished reports whether err finishes processing of a value successfully.
+func finished(err error) bool {
+	return errors.Is(err, ErrSkipRest) || errors.Is(err, ErrStop)
+}
+
 type Pipeline[T any] []HandlerFunc[T]
 
 // Execute starts pipeline processing.
+// Errors of stages are returned as *StageError.
 func Execute[T any](ctx context.Context, pipeline Pipeline[T], in T) (out T, err error) {
+	return execute(ctx, pipeline, nil, in, nil)
+}
+
+// execute runs pipeline, labels holds labels of stages resolved in advance, nil means look them up on demand.
+// Stopped is set if it is not nil and a stage has returned ErrStop.
+func execute[T any](ctx context.Context, pipeline Pipeline[T], labels []map[string]string, in T, stopped *bool) (out T, err error) {
+	md := MetadataFrom(ctx)
+	if md == nil {
+		ctx, md = WithMetadata(ctx)
+	}
+
+	nested := scopeFrom(ctx) != nil
+	parent := ctx
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	ctx, s := newScope(ctx, false)
+	s.cancel = cancel
+
 	defer func() {
+		err = s.finish(ctx, err)
+	}()
+
+	defer func() {
+		if !recovering(ctx) {
+			return
+		}
+
 		if rec := recover(); rec != nil {
-			err = fmt.Errorf("pipeline: recovered panic: %s: \n%s", rec, debug.Stack())
+			err = newPanicError(rec)
+			stage := int(s.stage.Load())
+			err = stageError(ctx, stage, err, md, labelsAt(pipeline, labels, stage))
 		}
 	}()
 
-	for _, handler := range pipeline {
+	hook := hookFrom(ctx)
+
+	// canceled returns the error of the run canceled by Cancel while stage i was running, e.g. by a hook
+	// which has failed to audit the result of the last stage, nil otherwise.
+	canceled := func(i int) error {
+		if ctx.Err() == nil || parent.Err() != nil {
+			return nil
+		}
+
+		return stageError(ctx, i, cause(ctx), md, labelsAt(pipeline, labels, i))
+	}
+
+	for i, handler := range pipeline {
 		select {
 		case <-ctx.Done():
-			return out, ctx.Err()
+			return out, stageError(ctx, i, cause(ctx), md, labelsAt(pipeline, labels, i))
 		default:
 		}
 
-		out, err = handler(ctx, in)
+		s.stage.Store(int32(i))
+
+		var l map[string]string
+
+		if hook != nil {
+			l = labelsAt(pipeline, labels, i)
+		}
+
+		out, err = handle(ctx, hook, i, handler, l, in)
+		if errors.Is(err, ErrStop) {
+			if stopped != nil {
+				*stopped = true
+			}
+
+			if nested {
+				return out, err
+			}
+		}
+
+		if finished(err) {
+			return out, canceled(i)
+		}
+
 		if err != nil {
-			return out, err
+			return out, stageError(ctx, i, err, md, labelsAt(pipeline, labels, i))
 		}
 
 		in = out
@@ -36,67 +123,251 @@ func Execute[T any](ctx context.Context, pipeline Pipeline[T], in T) (out T, err
 
 	out = in
 
+	if len(pipeline) > 0 {
+		return out, canceled(len(pipeline) - 1)
+	}
+
 	return out, nil
 }
 
+// executeStopped is Execute returning nil error for a run stopped by ErrStop, stopped is set then.
+// Callers running nested pipelines pass the stop to their enclosing run with stopResult.
+func executeStopped[T any](ctx context.Context, pipeline Pipeline[T], in T, stopped *bool) (out T, err error) {
+	out, err = execute(ctx, pipeline, nil, in, stopped)
+	if errors.Is(err, ErrStop) {
+		err = nil
+	}
+
+	return out, err
+}
+
+// stopResult returns ErrStop for a run nested into a stage, so the enclosing run stops too, nil otherwise.
+func stopResult(ctx context.Context) error {
+	if scopeFrom(ctx) != nil {
+		return ErrStop
+	}
+
+	return nil
+}
+
+func labelsAt[T any](pipeline Pipeline[T], labels []map[string]string, i int) map[string]string {
+	if labels != nil {
+		return labels[i]
+	}
+
+	return stageLabels(pipeline[i])
+}
+
 // Parallel distributes 'in' batch between jobs and executes piplene inside of separated routines.
-// Order of results will be same as input.
-func Parallel[T any](ctx context.Context, pipeline Pipeline[[]T], in []T, jobs int) (out []T, err error) {
+// Order of results will be same as input unless Unordered is given.
+// When some of batches fail, out holds results of the succeeded batches in input order
+// and err is *PartialError describing every batch.
+// When ctx is done during the run, batches which have not been started yet are skipped
+// and err is *RunReport telling state of every batch.
+// When a stage returns ErrStop, batches are no longer started and the running ones are canceled, out holds
+// results of the batches completed so far; Parallel called from a stage passes ErrStop to the enclosing run.
+// Parallel returns after all routines it has started have returned, they are run by the Executor attached to ctx.
+func Parallel[T any](ctx context.Context, pipeline Pipeline[[]T], in []T, jobs int, opts ...ParallelOption) (out []T, err error) {
 	if jobs <= 0 {
 		panic("jobs value must be greater than zero!")
 	}
 
-	var wg sync.WaitGroup
+	return parallel(ctx, pipeline, in, jobs, executorFrom(ctx), opts)
+}
+
+// parallel implements Parallel running batches by exec.
+func parallel[T any](ctx context.Context, pipeline Pipeline[[]T], in []T, jobs int, exec Executor, opts []ParallelOption) (out []T, err error) {
+	cfg := newParallelConfig(opts)
+	parent := ctx
 
-	batchSize := len(in) / jobs
+	var batches []Batch[T]
 
-	if len(in)%jobs > 0 {
-		batchSize += 1
+	if cfg.chunkSize > 0 {
+		batches = chunk[T](len(in), cfg.chunkSize)
+	} else {
+		batches = split[T](len(in), jobs)
+	}
+
+	limit := jobs
+	if cfg.maxInFlight > 0 {
+		limit = cfg.maxInFlight
 	}
 
-	outputData := make([][]T, jobs)
-	outputErr := make([]error, jobs)
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	done := make(chan int, len(batches))
+	stops := make([]bool, len(batches))
+	stopped := false
+	running := 0
+	completed := make([]int, 0, len(batches))
+
+	progress := newProgressTracker(len(in), cfg.onProgress)
+
+	wait := func() {
+		i := <-done
+		b := &batches[i]
 
-	max := jobs * batchSize
+		if stops[i] && b.Err == nil && !stopped {
+			stopped = true
+			cancel(errRunStopped)
+		}
+
+		b.State = batchState(parent, b.Err)
+
+		if b.State == BatchFailed && interrupted(ctx, b.Err) {
+			b.State = BatchInterrupted
+		}
 
-	if max > len(in) {
-		max = len(in)
+		progress.add(b.Len, b.Err != nil)
+		running--
+		completed = append(completed, i)
+
+		if b.Err != nil && cfg.failFast {
+			cancel(fmt.Errorf("pipeline: batch %d has failed", b.Index))
+		}
 	}
 
-	var beg, end int
+	for i := range batches {
+		for running > 0 && (running >= cfg.limit(limit) || cfg.overMemoryLimit()) {
+			wait()
+		}
 
-	for i := 0; i < jobs-1 && end < max; i++ {
-		beg = i * batchSize
-		end = (i + 1) * batchSize
+		b := &batches[i]
 
-		var batch []T
+		if parent.Err() != nil || stopped || (cfg.failFast && ctx.Err() != nil) {
+			b.Err = cause(ctx)
+			b.State = BatchNotStarted
+			progress.add(b.Len, true)
+			completed = append(completed, i)
 
-		if end <= max {
-			batch = in[beg:end]
-		} else {
-			batch = in[beg:]
+			continue
 		}
 
-		wg.Add(1)
-		go func(job int) {
-			defer wg.Done()
-			outputData[job], outputErr[job] = Execute(ctx, pipeline, batch)
-		}(i)
+		i := i
+
+		running++
+		exec.Go(func() {
+			defer func() { done <- i }()
+			defer cfg.lock()()
+
+			defer func() {
+				if !recovering(ctx) {
+					return
+				}
+
+				if rec := recover(); rec != nil {
+					b.Out, b.Err = nil, newPanicError(rec)
+				}
+			}()
+
+			b.Out, b.Err = runBatch(withBatch(ctx, b.Index), cfg.checkpoint, pipeline, b, cloneBatch(&cfg, in[b.Offset:b.Offset+b.Len]), &stops[i])
+		})
 	}
 
-	wg.Wait()
+	for running > 0 {
+		wait()
+	}
 
-	if len(outputData) > len(outputErr) {
-		panic("lenghts of outputErr and outputData must be equal!")
+	var order []int
+
+	if cfg.unordered {
+		order = completed
 	}
 
-	for i, v := range outputData {
-		err := outputErr[i]
-		if err != nil {
-			return nil, err
+	out, err = gather(batches, order, stopped)
+
+	if parent.Err() != nil {
+		return out, &RunReport[T]{Err: cause(parent), Batches: batches}
+	}
+
+	if err != nil {
+		return out, err
+	}
+
+	if err := clearCheckpoint(parent, cfg.checkpoint, batches); err != nil {
+		return out, err
+	}
+
+	if stopped {
+		return out, stopResult(parent)
+	}
+
+	return out, nil
+}
+
+// batchState returns state of a batch which has returned err or has been skipped if err is not nil.
+func batchState(ctx context.Context, err error) BatchState {
+	switch {
+	case err == nil:
+		return BatchCompleted
+	case ctx.Err() == nil:
+		return BatchFailed
+	default:
+		return BatchInterrupted
+	}
+}
+
+// interrupted reports whether err is caused by cancellation of ctx shared by batches, like by FailFast
+// after another batch has failed.
+func interrupted(ctx context.Context, err error) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+
+	return errors.Is(err, context.Cause(ctx)) || errors.Is(err, context.Canceled)
+}
+
+// split divides n elements into at most jobs batches of equal size.
+func split[T any](n, jobs int) []Batch[T] {
+	batchSize := n / jobs
+
+	if n%jobs > 0 {
+		batchSize += 1
+	}
+
+	return chunk[T](n, batchSize)
+}
+
+// chunk divides n elements into batches of batchSize elements, the last one may be shorter.
+func chunk[T any](n, batchSize int) []Batch[T] {
+	var batches []Batch[T]
+
+	for beg := 0; beg < n; beg += batchSize {
+		end := beg + batchSize
+
+		if end > n {
+			end = n
+		}
+
+		batches = append(batches, Batch[T]{Index: len(batches), Offset: beg, Len: end - beg})
+	}
+
+	return batches
+}
+
+// gather concatenates outputs of succeeded batches in order of indexes, nil means input order.
+// It returns *PartialError if any batch has failed, batches interrupted or skipped by the stop of the run do not count.
+func gather[T any](batches []Batch[T], order []int, stopped bool) (out []T, err error) {
+	failed := false
+
+	for i := range batches {
+		if order != nil {
+			i = order[i]
+		}
+
+		b := batches[i]
+
+		if b.Err != nil {
+			failed = failed || !stopped || (b.State != BatchInterrupted && b.State != BatchNotStarted)
+			continue
 		}
 
-		out = append(out, v...)
+		out = append(out, b.Out...)
+	}
+
+	if failed {
+		return out, &PartialError[T]{Batches: batches}
 	}
 
 	return out, nil
@@ -105,17 +376,31 @@ func Parallel[T any](ctx context.Context, pipeline Pipeline[[]T], in []T, jobs i
 // ForEach returns new handler over []T with applied handle function to every element.
 func ForEach[T any](handle HandlerFunc[T]) HandlerFunc[[]T] {
 	fn := func(ctx context.Context, in []T) (out []T, err error) {
+		n := 0
+
 		for i, v := range in {
-			in[i], err = handle(ctx, v)
-			if err != nil {
+			v, err = handle(ctx, v)
+
+			switch {
+			case errors.Is(err, ErrDropped):
+				continue
+			case errors.Is(err, ErrStop):
+				in[n] = v
+				return append(in[:n+1], in[i+1:]...), err
+			case err != nil && !errors.Is(err, ErrSkipRest):
 				return out, err
 			}
+
+			in[n] = v
+			n++
 		}
 
-		out = in
+		out = in[:n]
 
 		return out, nil
 	}
 
-	return fn
+	clone := func() any { return ForEach(cloneHandler(handle)) }
+
+	return describe(fn, describeInfo{middleware: "ForEach", inner: handle, clone: clone})
 }
@@ -0,0 +1,159 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// panicOn returns stage panicking on batches holding v.
+func panicOn(v int) HandlerFunc[[]int] {
+	fn := func(ctx context.Context, in []int) (out []int, err error) {
+		for _, e := range in {
+			if e == v {
+				panic("boom")
+			}
+		}
+
+		return in, nil
+	}
+
+	return fn
+}
+
+func TestParallelPanic(t *testing.T) {
+	in := []int{0, 1, 2, 3}
+
+	out, err := Parallel(context.Background(), Pipeline[[]int]{panicOn(2)}, in, len(in))
+
+	var perr *PartialError[int]
+	if !errors.As(err, &perr) {
+		t.Fatalf("got error %v, want *PartialError", err)
+	}
+
+	if want := []int{0, 1, 3}; !slices.Equal(out, want) {
+		t.Fatalf("got output %v, want %v", out, want)
+	}
+
+	if n := len(perr.Completed()); n != 3 {
+		t.Fatalf("got %d completed batches, want 3", n)
+	}
+
+	var berr *BatchError
+	if !errors.As(err, &berr) {
+		t.Fatalf("got error %v, want *BatchError", err)
+	}
+
+	if berr.Batch != 2 || berr.Offset != 2 {
+		t.Fatalf("got batch %d at offset %d, want batch 2 at offset 2", berr.Batch, berr.Offset)
+	}
+
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("got error %v, want *PanicError", err)
+	}
+
+	if pe.Value != "boom" {
+		t.Fatalf("got panic value %v, want boom", pe.Value)
+	}
+
+	if !strings.Contains(string(pe.Stack), "panicOn") {
+		t.Fatalf("stack does not contain the panicking stage:\n%s", pe.Stack)
+	}
+}
+
+func TestParallelPanicFailFast(t *testing.T) {
+	in := []int{0, 1, 2, 3}
+
+	out, err := Parallel(context.Background(), Pipeline[[]int]{panicOn(0)}, in, len(in), MaxInFlight(1), FailFast())
+
+	var perr *PartialError[int]
+	if !errors.As(err, &perr) {
+		t.Fatalf("got error %v, want *PartialError", err)
+	}
+
+	if len(out) != 0 {
+		t.Fatalf("got output %v, want none", out)
+	}
+
+	failed := perr.Failed()
+	if len(failed) != len(in) {
+		t.Fatalf("got %d failed batches, want %d", len(failed), len(in))
+	}
+
+	var pe *PanicError
+	if !errors.As(failed[0].Err, &pe) {
+		t.Fatalf("got error %v of the first batch, want *PanicError", failed[0].Err)
+	}
+
+	for _, b := range failed[1:] {
+		if errors.As(b.Err, &pe) {
+			t.Fatalf("batch %d has failed with panic, want it skipped", b.Index)
+		}
+
+		if b.State != BatchNotStarted {
+			t.Fatalf("got state %s of batch %d, want %s", b.State, b.Index, BatchNotStarted)
+		}
+	}
+}
+
+func TestParallelWithoutRecover(t *testing.T) {
+	if os.Getenv("PIPE_TEST_PANIC") == "1" {
+		ctx := WithoutRecover(context.Background())
+		Parallel(ctx, Pipeline[[]int]{panicOn(1)}, []int{0, 1}, 2)
+
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestParallelWithoutRecover$")
+	cmd.Env = append(os.Environ(), "PIPE_TEST_PANIC=1")
+
+	output, err := cmd.CombinedOutput()
+
+	var exit *exec.ExitError
+	if !errors.As(err, &exit) {
+		t.Fatalf("got error %v, want the process to crash", err)
+	}
+
+	if !strings.Contains(string(output), "panic: boom") {
+		t.Fatalf("output does not contain the panic:\n%s", output)
+	}
+}
+
+func TestParallelFailFastStates(t *testing.T) {
+	errBroken := errors.New("broken")
+
+	// The batch holding 0 fails, the others wait until FailFast cancels them.
+	h := func(ctx context.Context, in []int) ([]int, error) {
+		if in[0] == 0 {
+			return nil, errBroken
+		}
+
+		<-ctx.Done()
+
+		return nil, context.Cause(ctx)
+	}
+
+	_, err := Parallel(context.Background(), Pipeline[[]int]{h}, []int{0, 1, 2}, 3, FailFast())
+
+	var perr *PartialError[int]
+	if !errors.As(err, &perr) {
+		t.Fatalf("got error %v, want *PartialError", err)
+	}
+
+	want := []BatchState{BatchFailed, BatchInterrupted, BatchInterrupted}
+
+	for i, b := range perr.Batches {
+		if b.State != want[i] {
+			t.Fatalf("got state %s of batch %d, want %s", b.State, i, want[i])
+		}
+	}
+
+	if !errors.Is(perr.Batches[0].Err, errBroken) {
+		t.Fatalf("got error %v of the failed batch, want %v", perr.Batches[0].Err, errBroken)
+	}
+}
@@ -0,0 +1,393 @@
+// Package pipebench runs pipelines of synthetic stages and reports their throughput, latency and allocations,
+// so jobs can be sized and scheduling modes compared before real stages are written.
+package pipebench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/WinPooh32/pipe"
+)
+
+// ErrInjected is returned by synthetic stages failing on purpose, it wraps pipe.ErrDropped,
+// so failed elements are dropped and the run goes on in every mode.
+var ErrInjected = fmt.Errorf("pipebench: injected failure: %w", pipe.ErrDropped)
+
+// Distribution returns random durations.
+type Distribution func(r *rand.Rand) time.Duration
+
+// Constant returns distribution of exactly d.
+func Constant(d time.Duration) Distribution {
+	return func(r *rand.Rand) time.Duration {
+		return d
+	}
+}
+
+// Uniform returns distribution uniform in [min, max].
+func Uniform(min, max time.Duration) Distribution {
+	if max < min {
+		panic("bounds must satisfy min <= max!")
+	}
+
+	return func(r *rand.Rand) time.Duration {
+		return min + time.Duration(r.Int63n(int64(max-min)+1))
+	}
+}
+
+// Exponential returns exponential distribution with mean, it models independent arrivals.
+func Exponential(mean time.Duration) Distribution {
+	return func(r *rand.Rand) time.Duration {
+		return time.Duration(r.ExpFloat64() * float64(mean))
+	}
+}
+
+// Normal returns normal distribution with mean and standard deviation stddev, negative durations are zero.
+func Normal(mean, stddev time.Duration) Distribution {
+	return func(r *rand.Rand) time.Duration {
+		return max(0, time.Duration(r.NormFloat64()*float64(stddev))+mean)
+	}
+}
+
+// Stage describes a synthetic stage, zero fields do nothing.
+type Stage struct {
+	// Latency is the time the stage waits without using CPU, like a call of a remote service.
+	Latency Distribution
+	// CPU is the time the stage keeps CPU busy.
+	CPU Distribution
+	// ErrorRate is the probability that the stage fails with ErrInjected.
+	ErrorRate float64
+}
+
+// Workload describes elements and stages of a synthetic pipeline.
+type Workload struct {
+	Elements int
+	Stages   []Stage
+	// Seed seeds random choices of the stages. Choices of a call depend only on the seed, the stage
+	// and the element, so they are reproducible in every mode.
+	Seed int64
+}
+
+// Item is an element of a synthetic pipeline.
+type Item struct {
+	ID int
+	// start is when the item has entered the first stage.
+	start time.Time
+}
+
+// Mode executes a pipeline over input, e.g. with one of pipe schedulers.
+type Mode struct {
+	Name string
+	Run  func(ctx context.Context, pipeline pipe.Pipeline[Item], in []Item) error
+}
+
+// Sequential executes pipeline for every element in turn with pipe.Execute.
+func Sequential() Mode {
+	run := func(ctx context.Context, pipeline pipe.Pipeline[Item], in []Item) error {
+		for _, v := range in {
+			if _, err := pipe.Execute(ctx, pipeline, v); err != nil && !errors.Is(err, ErrInjected) {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return Mode{Name: "sequential", Run: run}
+}
+
+// Parallel executes pipeline with pipe.Parallel over jobs batches, every stage is applied with pipe.ForEach.
+func Parallel(jobs int, opts ...pipe.ParallelOption) Mode {
+	run := func(ctx context.Context, pipeline pipe.Pipeline[Item], in []Item) error {
+		p := make(pipe.Pipeline[[]Item], len(pipeline))
+
+		for i, handler := range pipeline {
+			p[i] = pipe.ForEach(handler)
+		}
+
+		_, err := pipe.Parallel(ctx, p, in, jobs, opts...)
+
+		return err
+	}
+
+	return Mode{Name: fmt.Sprintf("parallel(jobs=%d)", jobs), Run: run}
+}
+
+// Stream executes pipeline as pipe.Stream, every stage has the given number of workers.
+func Stream(workers int, opts ...pipe.StageOption) Mode {
+	run := func(ctx context.Context, pipeline pipe.Pipeline[Item], in []Item) error {
+		s := pipe.NewStream[Item](append([]pipe.StageOption{pipe.Workers(workers)}, opts...)...)
+
+		for _, handler := range pipeline {
+			s.Via(handler)
+		}
+
+		return s.Run(ctx, pipe.FromSlice(in), func(ctx context.Context, v Item) error {
+			return nil
+		})
+	}
+
+	return Mode{Name: fmt.Sprintf("stream(workers=%d)", workers), Run: run}
+}
+
+// Pool executes pipeline with pipe.Pool of the given number of workers, values are submitted concurrently.
+func Pool(workers int, opts ...pipe.PoolOption) Mode {
+	run := func(ctx context.Context, pipeline pipe.Pipeline[Item], in []Item) error {
+		p := pipe.NewPool(pipeline, append([]pipe.PoolOption{pipe.PoolWorkers(workers, workers)}, opts...)...)
+
+		var (
+			wg   sync.WaitGroup
+			next atomic.Int64
+			errs = make([]error, 2*workers)
+		)
+
+		for s := range errs {
+			s := s
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for i := int(next.Add(1)) - 1; i < len(in); i = int(next.Add(1)) - 1 {
+					if _, err := p.Submit(ctx, in[i]); err != nil && !errors.Is(err, ErrInjected) {
+						errs[s] = err
+						return
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		return errors.Join(append(errs, p.Close())...)
+	}
+
+	return Mode{Name: fmt.Sprintf("pool(workers=%d)", workers), Run: run}
+}
+
+// Report is the result of a benchmark run.
+type Report struct {
+	Mode string
+	// Elements is the number of input elements, Failed is the number of them failed with ErrInjected.
+	Elements int
+	Failed   int
+	Elapsed  time.Duration
+	// Throughput is the number of completed elements per second.
+	Throughput float64
+	// Latency percentiles are taken over completed elements from entering the first stage to leaving the last one.
+	P50, P90, P99, Max time.Duration
+	// Allocs and Bytes are the number and size of heap allocations per element.
+	Allocs float64
+	Bytes  float64
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("%s: %d elements (%d failed) in %s, %.1f/s, latency p50=%s p90=%s p99=%s max=%s, %.1f allocs %.0f B per element",
+		r.Mode, r.Elements, r.Failed, r.Elapsed, r.Throughput, r.P50, r.P90, r.P99, r.Max, r.Allocs, r.Bytes)
+}
+
+// Run executes workload in mode and reports the results. Errors other than ErrInjected stop the run.
+func Run(ctx context.Context, w Workload, mode Mode) (Report, error) {
+	if w.Elements < 0 {
+		panic("elements value must not be negative!")
+	}
+
+	b := &bench{
+		latencies: make([]time.Duration, w.Elements),
+		seed:      w.Seed,
+		rands:     sync.Pool{New: func() any { return rand.New(&splitMix{}) }},
+	}
+
+	pipeline := b.pipeline(w.Stages)
+
+	in := make([]Item, w.Elements)
+
+	for i := range in {
+		in[i].ID = i
+	}
+
+	var before, after runtime.MemStats
+
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	err := mode.Run(ctx, pipeline, in)
+	elapsed := time.Since(start)
+
+	runtime.ReadMemStats(&after)
+
+	if err != nil {
+		return Report{}, err
+	}
+
+	return b.report(mode.Name, w.Elements, elapsed, &before, &after), nil
+}
+
+// bench holds state of a run.
+type bench struct {
+	seed int64
+	// rands holds generators reseeded for every call, so stages do not contend for a shared one.
+	rands sync.Pool
+
+	failed    atomic.Int64
+	latencies []time.Duration
+}
+
+func (b *bench) pipeline(stages []Stage) pipe.Pipeline[Item] {
+	p := make(pipe.Pipeline[Item], len(stages))
+
+	for i, s := range stages {
+		p[i] = pipe.Named(fmt.Sprintf("synthetic-%d", i), b.stage(s, i, i == len(stages)-1))
+	}
+
+	return p
+}
+
+func (b *bench) stage(s Stage, index int, last bool) pipe.HandlerFunc[Item] {
+	fn := func(ctx context.Context, in Item) (out Item, err error) {
+		if index == 0 {
+			in.start = time.Now()
+		}
+
+		latency, cpu, fail := b.draw(s, index, in.ID)
+
+		spin(cpu)
+
+		if latency > 0 {
+			t := time.NewTimer(latency)
+
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return out, context.Cause(ctx)
+			case <-t.C:
+			}
+		}
+
+		if fail {
+			b.failed.Add(1)
+			return out, ErrInjected
+		}
+
+		if last {
+			b.latencies[in.ID] = time.Since(in.start)
+		}
+
+		return in, nil
+	}
+
+	return fn
+}
+
+// draw returns random parameters of a call of stage s at index for element id.
+func (b *bench) draw(s Stage, index, id int) (latency, cpu time.Duration, fail bool) {
+	r := b.rands.Get().(*rand.Rand)
+	defer b.rands.Put(r)
+
+	r.Seed(b.seed ^ int64(index)<<40 ^ int64(id))
+
+	if s.Latency != nil {
+		latency = s.Latency(r)
+	}
+
+	if s.CPU != nil {
+		cpu = s.CPU(r)
+	}
+
+	fail = s.ErrorRate > 0 && r.Float64() < s.ErrorRate
+
+	return latency, cpu, fail
+}
+
+// splitMix is rand.Source64 generating SplitMix64 sequence, it is cheap to seed for every call.
+type splitMix struct {
+	x uint64
+}
+
+func (s *splitMix) Seed(seed int64) {
+	s.x = uint64(seed)
+}
+
+func (s *splitMix) Uint64() uint64 {
+	s.x += 0x9e3779b97f4a7c15
+
+	z := s.x
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+
+	return z ^ (z >> 31)
+}
+
+func (s *splitMix) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// spin keeps CPU busy for d.
+func spin(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	x := 1.0
+
+	for start := time.Now(); time.Since(start) < d; {
+		for i := 0; i < 1000; i++ {
+			x = math.Sqrt(x + float64(i))
+		}
+	}
+
+	sink.Store(math.Float64bits(x))
+}
+
+// sink keeps results of spin from being optimized away.
+var sink atomic.Uint64
+
+func (b *bench) report(mode string, n int, elapsed time.Duration, before, after *runtime.MemStats) Report {
+	r := Report{
+		Mode:     mode,
+		Elements: n,
+		Failed:   int(b.failed.Load()),
+		Elapsed:  elapsed,
+	}
+
+	completed := n - r.Failed
+
+	if elapsed > 0 {
+		r.Throughput = float64(completed) / elapsed.Seconds()
+	}
+
+	if n > 0 {
+		r.Allocs = float64(after.Mallocs-before.Mallocs) / float64(n)
+		r.Bytes = float64(after.TotalAlloc-before.TotalAlloc) / float64(n)
+	}
+
+	latencies := make([]time.Duration, 0, completed)
+
+	for _, d := range b.latencies {
+		if d > 0 {
+			latencies = append(latencies, d)
+		}
+	}
+
+	if len(latencies) == 0 {
+		return r
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	at := func(q float64) time.Duration {
+		return latencies[min(len(latencies)-1, int(q*float64(len(latencies))))]
+	}
+
+	r.P50, r.P90, r.P99, r.Max = at(0.5), at(0.9), at(0.99), latencies[len(latencies)-1]
+
+	return r
+}
@@ -0,0 +1,458 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultScaleInterval is the period of Pool scaling decisions when no ScaleInterval option is given.
+const DefaultScaleInterval = 100 * time.Millisecond
+
+// ErrPoolClosed is returned by Pool.Submit after the pool has been closed.
+var ErrPoolClosed = errors.New("pipeline: pool is closed")
+
+// PoolOption configures Pool.
+type PoolOption func(*poolConfig)
+
+type poolConfig struct {
+	min      int
+	max      int
+	queue    int
+	interval time.Duration
+	onScale  func(from, to int)
+	events   context.Context
+	lock     bool
+	stall    time.Duration
+	onPause  func(paused bool)
+	aging    time.Duration
+}
+
+// PoolWorkers sets bounds of the number of pool workers.
+// Pool starts with min workers and scales between min and max depending on load.
+func PoolWorkers(min, max int) PoolOption {
+	if min <= 0 || max < min {
+		panic("workers bounds must satisfy 0 < min <= max!")
+	}
+
+	return func(c *poolConfig) {
+		c.min = min
+		c.max = max
+	}
+}
+
+// PoolQueue sets capacity of the pool queue, at least one value is queued.
+func PoolQueue(n int) PoolOption {
+	if n < 0 {
+		panic("queue value must not be negative!")
+	}
+
+	return func(c *poolConfig) {
+		c.queue = n
+	}
+}
+
+// ScaleInterval sets how often pool reconsiders the number of workers.
+func ScaleInterval(d time.Duration) PoolOption {
+	if d <= 0 {
+		panic("interval value must be greater than zero!")
+	}
+
+	return func(c *poolConfig) {
+		c.interval = d
+	}
+}
+
+// OnScale sets hook called after pool has changed the number of workers.
+func OnScale(fn func(from, to int)) PoolOption {
+	return func(c *poolConfig) {
+		c.onScale = fn
+	}
+}
+
+// PoolEvents makes pool report EventScaleUp and EventScaleDown to hooks attached to ctx with WithHook,
+// events are not bound to a run, so their stage is -1.
+func PoolEvents(ctx context.Context) PoolOption {
+	return func(c *poolConfig) {
+		c.events = ctx
+	}
+}
+
+// PoolStallTimeout sets period without completed values after which Health reports the busy pool as stalled,
+// it is DefaultStallTimeout by default.
+func PoolStallTimeout(d time.Duration) PoolOption {
+	if d <= 0 {
+		panic("timeout value must be greater than zero!")
+	}
+
+	return func(c *poolConfig) {
+		c.stall = d
+	}
+}
+
+// PoolOnPause sets hook called after the pool has been paused or resumed.
+func PoolOnPause(fn func(paused bool)) PoolOption {
+	return func(c *poolConfig) {
+		c.onPause = fn
+	}
+}
+
+// PoolLockOSThread makes every worker run locked to its own OS thread for its lifetime, see runtime.LockOSThread.
+// Per-worker state of FromStage and PerWorker handlers is created on that thread, so handlers wrapping
+// cgo libraries with thread affinity requirements may keep thread-bound contexts there.
+// PoolWorkers limits the number of occupied threads.
+func PoolLockOSThread() PoolOption {
+	return func(c *poolConfig) {
+		c.lock = true
+	}
+}
+
+// Pool is a long-running set of workers executing pipeline for submitted values.
+//
+// Every interval pool compares the number of waiting values with the observed latency of the pipeline:
+// it starts as many workers as needed to drain the queue within the next interval
+// and stops one idle worker when the queue is empty.
+//
+// Workers run until Close, which returns after all routines of the pool have returned.
+// A value which Submit has given up on because of its ctx is still executed with that ctx.
+type Pool[T any] struct {
+	pipeline Pipeline[T]
+	cfg      poolConfig
+	stats    *pipelineStats
+	health   healthTracker
+
+	queue *taskQueue[T]
+	quit  chan struct{}
+	done  chan struct{}
+
+	pending atomic.Int64
+	busy    atomic.Int64
+	latency atomic.Int64
+
+	mu       sync.Mutex
+	closed   bool
+	workers  int
+	closeErr []error
+
+	senders sync.WaitGroup
+	wg      sync.WaitGroup
+}
+
+type task[T any] struct {
+	ctx  context.Context
+	in   T
+	out  T
+	err  error
+	done chan struct{}
+}
+
+// NewPool starts pool executing pipeline.
+func NewPool[T any](pipeline Pipeline[T], opts ...PoolOption) *Pool[T] {
+	procs := runtime.GOMAXPROCS(0)
+
+	cfg := poolConfig{
+		min:      1,
+		max:      procs,
+		queue:    procs,
+		interval: DefaultScaleInterval,
+		aging:    DefaultPriorityAging,
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	stats, pipeline := instrument(pipeline)
+
+	p := &Pool[T]{
+		pipeline: pipeline,
+		cfg:      cfg,
+		stats:    stats,
+		queue:    newTaskQueue[T](cfg.queue, cfg.aging),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	p.health.timeout = cfg.stall
+	p.health.start()
+	p.health.onPause = cfg.onPause
+
+	p.mu.Lock()
+	p.spawn(cfg.min)
+	p.mu.Unlock()
+
+	if cfg.min < cfg.max {
+		p.wg.Add(1)
+		go p.scale()
+	}
+
+	return p
+}
+
+// Submit executes pipeline for in on one of the workers and waits for the result.
+// Values are queued by priority given with WithPriority.
+func (p *Pool[T]) Submit(ctx context.Context, in T) (out T, err error) {
+	t := &task[T]{ctx: ctx, in: in, done: make(chan struct{})}
+
+	if err := p.enqueue(ctx, t); err != nil {
+		return out, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return out, cause(ctx)
+	case <-t.done:
+		return t.out, t.err
+	}
+}
+
+func (p *Pool[T]) enqueue(ctx context.Context, t *task[T]) error {
+	p.mu.Lock()
+
+	if p.closed {
+		p.mu.Unlock()
+		return ErrPoolClosed
+	}
+
+	p.senders.Add(1)
+	defer p.senders.Done()
+
+	p.mu.Unlock()
+
+	p.pending.Add(1)
+
+	if err := p.queue.push(ctx, t); err != nil {
+		p.pending.Add(-1)
+		return err
+	}
+
+	return nil
+}
+
+// Workers returns current number of workers.
+func (p *Pool[T]) Workers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.workers
+}
+
+// Health returns state of the pool, it is stalled when workers are busy but none has completed a value
+// within the stall timeout.
+func (p *Pool[T]) Health() Health {
+	return p.health.report()
+}
+
+// Pause makes workers stop taking submitted values and waits until the running ones complete or ctx is done.
+// Submit keeps queueing values meanwhile, they are executed after Resume or Close.
+func (p *Pool[T]) Pause(ctx context.Context) error {
+	return p.health.pause(ctx)
+}
+
+// Resume makes workers take submitted values again.
+func (p *Pool[T]) Resume() {
+	p.health.setPaused(false)
+}
+
+// PoolStats is a snapshot of a pool.
+type PoolStats struct {
+	Workers int
+	// Busy is the number of workers executing the pipeline.
+	Busy int
+	// Queued is the number of submitted values waiting for a worker.
+	Queued int
+	// Latency is the moving average of the time taken by the pipeline.
+	Latency  time.Duration
+	Pipeline PipelineStats
+}
+
+// Stats returns snapshot of the pool and counters of its pipeline, it is safe for concurrent use.
+func (p *Pool[T]) Stats() PoolStats {
+	return PoolStats{
+		Workers:  p.Workers(),
+		Busy:     int(p.busy.Load()),
+		Queued:   int(p.pending.Load()),
+		Latency:  time.Duration(p.latency.Load()),
+		Pipeline: p.stats.snapshot(),
+	}
+}
+
+// Close stops accepting new values, waits for queued ones and stops workers.
+// It returns errors of closing stages owned by workers.
+func (p *Pool[T]) Close() error {
+	p.mu.Lock()
+
+	if p.closed {
+		p.mu.Unlock()
+		return ErrPoolClosed
+	}
+
+	p.closed = true
+
+	p.mu.Unlock()
+
+	p.health.set(HealthDraining)
+	defer p.health.set(HealthStopped)
+
+	p.Resume()
+
+	p.senders.Wait()
+	close(p.done)
+
+	p.wg.Wait()
+
+	return errors.Join(p.closeErr...)
+}
+
+// spawn starts n workers, must be called with locked mu.
+func (p *Pool[T]) spawn(n int) {
+	p.workers += n
+
+	for i := 0; i < n; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+}
+
+func (p *Pool[T]) work() {
+	defer p.wg.Done()
+
+	if p.cfg.lock {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+	}
+
+	s := &scope{}
+
+	defer func() {
+		if err := s.close(); err != nil {
+			p.mu.Lock()
+			p.closeErr = append(p.closeErr, err)
+			p.mu.Unlock()
+		}
+	}()
+
+	for {
+		select {
+		case <-p.health.ready():
+		case <-p.quit:
+			return
+		}
+
+		select {
+		case <-p.queue.items:
+			p.run(s, p.queue.pop())
+		case <-p.quit:
+			return
+		case <-p.done:
+			for {
+				select {
+				case <-p.queue.items:
+					p.run(s, p.queue.pop())
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *Pool[T]) run(s *scope, t *task[T]) {
+	// The task taken at the moment of Pause waits for Resume.
+	p.health.begin(nil)
+
+	p.pending.Add(-1)
+	p.busy.Add(1)
+	defer p.busy.Add(-1)
+
+	defer close(t.done)
+
+	start := time.Now()
+
+	t.out, t.err = Execute(withWorker(t.ctx, s), p.pipeline, t.in)
+	p.stats.observe(t.err)
+	p.health.end(t.err)
+
+	p.observe(time.Since(start))
+}
+
+// observe updates moving average of the pipeline latency.
+func (p *Pool[T]) observe(d time.Duration) {
+	observeLatency(&p.latency, d)
+}
+
+func (p *Pool[T]) scale() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cfg.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			from, to := p.rescale()
+			if from == to {
+				continue
+			}
+
+			if p.cfg.onScale != nil {
+				p.cfg.onScale(from, to)
+			}
+
+			if p.cfg.events != nil {
+				kind := EventScaleUp
+				if to < from {
+					kind = EventScaleDown
+				}
+
+				emit(p.cfg.events, Event{Kind: kind, Workers: to})
+			}
+		}
+	}
+}
+
+func (p *Pool[T]) rescale() (from, to int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return p.workers, p.workers
+	}
+
+	from = p.workers
+	pending := int(p.pending.Load())
+
+	switch {
+	case pending > 0 && p.workers < p.cfg.max:
+		p.spawn(p.needed(pending))
+
+	case pending == 0 && p.workers > p.cfg.min && int(p.busy.Load()) < p.workers:
+		select {
+		case p.quit <- struct{}{}:
+			p.workers--
+		default:
+		}
+	}
+
+	return from, p.workers
+}
+
+// needed returns number of workers to add for draining pending values within the scale interval.
+func (p *Pool[T]) needed(pending int) int {
+	n := needed(pending, time.Duration(p.latency.Load()), p.cfg.interval)
+
+	if n < 1 {
+		n = 1
+	}
+
+	if n > p.cfg.max-p.workers {
+		n = p.cfg.max - p.workers
+	}
+
+	return n
+}
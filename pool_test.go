@@ -0,0 +1,85 @@
+package pipe
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPoolEvents(t *testing.T) {
+	defer checkLeaks(t)()
+
+	var (
+		mu     sync.Mutex
+		events []Event
+	)
+
+	hook := func(ctx context.Context, e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		events = append(events, e)
+	}
+
+	slow := func(ctx context.Context, in int) (int, error) {
+		time.Sleep(5 * time.Millisecond)
+		return in, nil
+	}
+
+	p := NewPool(Pipeline[int]{slow}, PoolWorkers(1, 4), PoolQueue(16), ScaleInterval(time.Millisecond),
+		PoolEvents(WithHook(context.Background(), hook)))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			if _, err := p.Submit(context.Background(), i); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	// Idle workers are stopped one per interval.
+	deadline := time.Now().Add(time.Second)
+
+	for p.Workers() > 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	workers := 1
+	seen := map[EventKind]bool{}
+
+	for _, e := range events {
+		switch {
+		case e.Kind == EventScaleUp && e.Workers > workers:
+		case e.Kind == EventScaleDown && e.Workers < workers:
+		default:
+			t.Fatalf("got event %s to %d workers from %d", e.Kind, e.Workers, workers)
+		}
+
+		if e.Stage != -1 {
+			t.Fatalf("got stage %d of event %s, want -1", e.Stage, e.Kind)
+		}
+
+		workers = e.Workers
+		seen[e.Kind] = true
+	}
+
+	if !seen[EventScaleUp] || !seen[EventScaleDown] {
+		t.Fatalf("got events %v, want both scale up and scale down", seen)
+	}
+}
@@ -0,0 +1,133 @@
+package pipe
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultPriorityAging is the waiting time worth one priority level when no PoolAging option is given.
+const DefaultPriorityAging = time.Second
+
+type priorityKey struct{}
+
+// WithPriority returns ctx making Pool.Submit called with it queue the value with priority,
+// values of higher priority are taken by workers first. The default priority is zero.
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+func priorityFrom(ctx context.Context) int {
+	p, _ := ctx.Value(priorityKey{}).(int)
+	return p
+}
+
+// PoolAging sets waiting time which raises priority of a queued value by one level,
+// so values of low priority are not starved by a steady flow of urgent ones.
+func PoolAging(d time.Duration) PoolOption {
+	if d <= 0 {
+		panic("aging value must be greater than zero!")
+	}
+
+	return func(c *poolConfig) {
+		c.aging = d
+	}
+}
+
+// taskQueue is a bounded priority queue of tasks.
+type taskQueue[T any] struct {
+	start time.Time
+	aging time.Duration
+
+	// slots limits the number of queued tasks, items holds a token for every queued task.
+	slots chan struct{}
+	items chan struct{}
+
+	mu    sync.Mutex
+	tasks taskHeap[T]
+	seq   uint64
+}
+
+func newTaskQueue[T any](capacity int, aging time.Duration) *taskQueue[T] {
+	capacity = max(capacity, 1)
+
+	return &taskQueue[T]{
+		start: time.Now(),
+		aging: aging,
+		slots: make(chan struct{}, capacity),
+		items: make(chan struct{}, capacity),
+	}
+}
+
+// push queues t waiting for a free slot until ctx is done.
+func (q *taskQueue[T]) push(ctx context.Context, t *task[T]) error {
+	select {
+	case <-ctx.Done():
+		return cause(ctx)
+	case q.slots <- struct{}{}:
+	}
+
+	// Waiting for aging makes a value as urgent as the one of the next priority level,
+	// so the key does not depend on the time it is compared at.
+	waited := float64(time.Since(q.start)) / float64(q.aging)
+
+	q.mu.Lock()
+	q.seq++
+	heap.Push(&q.tasks, queued[T]{task: t, key: float64(priorityFrom(t.ctx)) - waited, seq: q.seq})
+	q.mu.Unlock()
+
+	q.items <- struct{}{}
+
+	return nil
+}
+
+// pop returns the most urgent task, it must be called after a token has been received from items.
+func (q *taskQueue[T]) pop() *task[T] {
+	q.mu.Lock()
+	t := heap.Pop(&q.tasks).(queued[T]).task
+	q.mu.Unlock()
+
+	<-q.slots
+
+	return t
+}
+
+type queued[T any] struct {
+	task *task[T]
+	key  float64
+	seq  uint64
+}
+
+// taskHeap orders tasks by key descending, then by queueing order.
+type taskHeap[T any] []queued[T]
+
+func (h taskHeap[T]) Len() int {
+	return len(h)
+}
+
+func (h taskHeap[T]) Less(i, j int) bool {
+	if h[i].key != h[j].key {
+		return h[i].key > h[j].key
+	}
+
+	return h[i].seq < h[j].seq
+}
+
+func (h taskHeap[T]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *taskHeap[T]) Push(x any) {
+	*h = append(*h, x.(queued[T]))
+}
+
+func (h *taskHeap[T]) Pop() any {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = queued[T]{}
+	*h = old[:n-1]
+
+	return x
+}
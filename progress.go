@@ -0,0 +1,77 @@
+package pipe
+
+import (
+	"time"
+)
+
+// Progress describes how far a job has got.
+type Progress struct {
+	// Completed is the number of processed elements including the failed ones.
+	Completed int
+	// Failed is the number of elements of failed batches.
+	Failed int
+	// Total is the number of elements of the job.
+	Total   int
+	Elapsed time.Duration
+	// Throughput is the number of elements completed per second.
+	Throughput float64
+	// ETA is the estimated time until the job has completed, zero when it is unknown or the job is done.
+	ETA time.Duration
+}
+
+// Percent returns completed part of the job in range [0, 100].
+func (p Progress) Percent() float64 {
+	if p.Total == 0 {
+		return 100
+	}
+
+	return 100 * float64(p.Completed) / float64(p.Total)
+}
+
+// progressTracker reports progress of a job of total elements to fn if it is not nil.
+type progressTracker struct {
+	fn    func(Progress)
+	start time.Time
+	p     Progress
+}
+
+func newProgressTracker(total int, fn func(Progress)) *progressTracker {
+	return &progressTracker{fn: fn, start: time.Now(), p: Progress{Total: total}}
+}
+
+// add accounts n completed elements.
+func (t *progressTracker) add(n int, failed bool) {
+	if t.fn == nil {
+		return
+	}
+
+	t.p.Completed += n
+
+	if failed {
+		t.p.Failed += n
+	}
+
+	t.p.Elapsed = time.Since(t.start)
+	t.p.Throughput = rate(t.p.Completed, t.p.Elapsed)
+	t.p.ETA = eta(t.p.Total-t.p.Completed, t.p.Throughput)
+
+	t.fn(t.p)
+}
+
+// rate returns number of events per second.
+func rate(n int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+
+	return float64(n) / d.Seconds()
+}
+
+// eta returns time to process remaining elements at throughput per second.
+func eta(remaining int, throughput float64) time.Duration {
+	if remaining <= 0 || throughput <= 0 {
+		return 0
+	}
+
+	return time.Duration(float64(remaining) / throughput * float64(time.Second))
+}
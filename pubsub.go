@@ -0,0 +1,151 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrBusClosed is returned by Bus.Publish after the bus is closed.
+var ErrBusClosed = errors.New("pipeline: bus closed")
+
+// Publisher publishes values to subscribers.
+type Publisher[T any] interface {
+	Publish(ctx context.Context, v T) error
+}
+
+// Publish returns stage publishing every value to bus, values are passed further unchanged.
+func Publish[T any](bus Publisher[T]) HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		if err := bus.Publish(ctx, in); err != nil {
+			return out, err
+		}
+
+		return in, nil
+	}
+
+	return fn
+}
+
+// Bus is in-process Publisher delivering every value to all its subscribers.
+// Publish waits until every subscriber has received the value, so a slow subscriber slows down publishers,
+// give it a buffer to smooth bursts. Zero value is ready to use, Bus is safe for concurrent use.
+type Bus[T any] struct {
+	mu     sync.Mutex
+	subs   map[*subscription[T]]struct{}
+	closed bool
+}
+
+type subscription[T any] struct {
+	// mu is held for reading by senders, so ch is closed only when nobody sends to it.
+	mu   sync.RWMutex
+	ch   chan T
+	done chan struct{}
+	once sync.Once
+}
+
+// Subscribe returns channel receiving values published since now with buffer of size n
+// and function canceling the subscription. The channel is closed when the subscription is canceled or the bus is closed.
+func (b *Bus[T]) Subscribe(n int) (ch <-chan T, cancel func()) {
+	if n < 0 {
+		panic("buffer value must not be negative!")
+	}
+
+	s := &subscription[T]{ch: make(chan T, n), done: make(chan struct{})}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		s.close()
+		return s.ch, func() {}
+	}
+
+	if b.subs == nil {
+		b.subs = make(map[*subscription[T]]struct{})
+	}
+
+	b.subs[s] = struct{}{}
+
+	cancel = func() {
+		b.mu.Lock()
+		delete(b.subs, s)
+		b.mu.Unlock()
+
+		s.close()
+	}
+
+	return s.ch, cancel
+}
+
+// Publish delivers v to every subscriber, it returns ErrBusClosed after Close.
+func (b *Bus[T]) Publish(ctx context.Context, v T) error {
+	b.mu.Lock()
+
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBusClosed
+	}
+
+	subs := make([]*subscription[T], 0, len(b.subs))
+
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+
+	b.mu.Unlock()
+
+	for _, s := range subs {
+		if err := s.send(ctx, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close cancels all subscriptions, further publications fail with ErrBusClosed.
+func (b *Bus[T]) Close() {
+	b.mu.Lock()
+
+	b.closed = true
+	subs := b.subs
+	b.subs = nil
+
+	b.mu.Unlock()
+
+	for s := range subs {
+		s.close()
+	}
+}
+
+// send delivers v unless the subscription is canceled meanwhile.
+func (s *subscription[T]) send(ctx context.Context, v T) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	select {
+	case <-s.done:
+		return nil
+	default:
+	}
+
+	select {
+	case <-ctx.Done():
+		return cause(ctx)
+	case <-s.done:
+		return nil
+	case s.ch <- v:
+		return nil
+	}
+}
+
+func (s *subscription[T]) close() {
+	s.once.Do(func() {
+		close(s.done)
+
+		s.mu.Lock()
+		close(s.ch)
+		s.mu.Unlock()
+	})
+}
@@ -0,0 +1,297 @@
+package pipe
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultVisibility is the time a pulled message stays hidden from other consumers
+// when no Visibility option is given.
+const DefaultVisibility = 30 * time.Second
+
+// Message is a value pulled from Queue.
+type Message struct {
+	ID   string
+	Body []byte
+	// Attempt is the number of times the message has been delivered, starting from one.
+	Attempt int
+}
+
+// Queue is a work queue shared by processes, e.g. Redis lists or streams with consumer groups.
+type Queue interface {
+	// Push appends message body to the queue.
+	Push(ctx context.Context, body []byte) error
+	// Pull blocks until a message is available. The message is hidden from other consumers for visibility
+	// and delivered again after that unless it is acknowledged.
+	Pull(ctx context.Context, visibility time.Duration) (Message, error)
+	// Ack removes delivered message from the queue.
+	Ack(ctx context.Context, id string) error
+}
+
+// QueueOption configures QueueExecutor.
+type QueueOption func(*queueConfig)
+
+type queueConfig struct {
+	visibility  time.Duration
+	maxAttempts int
+	deadLetter  Queue
+}
+
+// Visibility sets how long a message being processed is hidden from other workers.
+// A failed message is retried by the queue when it becomes visible again.
+func Visibility(d time.Duration) QueueOption {
+	if d <= 0 {
+		panic("visibility value must be greater than zero!")
+	}
+
+	return func(c *queueConfig) {
+		c.visibility = d
+	}
+}
+
+// MaxAttempts limits deliveries of a message, the message failed n times is acknowledged
+// and moved to the dead letter queue if it is set.
+func MaxAttempts(n int) QueueOption {
+	if n <= 0 {
+		panic("attempts value must be greater than zero!")
+	}
+
+	return func(c *queueConfig) {
+		c.maxAttempts = n
+	}
+}
+
+// DeadLetter sets queue receiving messages which have run out of attempts.
+func DeadLetter(q Queue) QueueOption {
+	return func(c *queueConfig) {
+		c.deadLetter = q
+	}
+}
+
+// QueueExecutor executes pipeline for values passed through queue, so values enqueued by one process
+// are processed by workers of many processes.
+type QueueExecutor[T any] struct {
+	queue    Queue
+	codec    Codec[T]
+	pipeline Pipeline[T]
+	cfg      queueConfig
+}
+
+// NewQueueExecutor returns executor serializing values of queue with codec.
+func NewQueueExecutor[T any](queue Queue, codec Codec[T], pipeline Pipeline[T], opts ...QueueOption) *QueueExecutor[T] {
+	cfg := queueConfig{visibility: DefaultVisibility}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &QueueExecutor[T]{
+		queue:    queue,
+		codec:    codec,
+		pipeline: pipeline,
+		cfg:      cfg,
+	}
+}
+
+// Enqueue pushes in to the queue.
+func (e *QueueExecutor[T]) Enqueue(ctx context.Context, in T) error {
+	body, err := e.codec.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	return e.queue.Push(ctx, body)
+}
+
+// Work pulls values from the queue and executes pipeline for them in workers routines until ctx is done
+// or the queue fails. Message is acknowledged after pipeline succeeds.
+func (e *QueueExecutor[T]) Work(ctx context.Context, workers int) error {
+	if workers <= 0 {
+		panic("workers value must be greater than zero!")
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		spawn(ctx, func() {
+			defer wg.Done()
+
+			for ctx.Err() == nil {
+				if err := e.next(ctx); err != nil {
+					cancel(err)
+				}
+			}
+		})
+	}
+
+	wg.Wait()
+
+	return cause(ctx)
+}
+
+func (e *QueueExecutor[T]) next(ctx context.Context) error {
+	msg, err := e.queue.Pull(ctx, e.cfg.visibility)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		return err
+	}
+
+	var in T
+
+	err = e.codec.Unmarshal(msg.Body, &in)
+	if err == nil {
+		_, err = Execute(ctx, e.pipeline, in)
+	}
+
+	if err == nil {
+		return e.queue.Ack(ctx, msg.ID)
+	}
+
+	// The run has been interrupted by shutdown, the message is redelivered after the visibility timeout.
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	if e.cfg.maxAttempts == 0 || msg.Attempt < e.cfg.maxAttempts {
+		return nil
+	}
+
+	if e.cfg.deadLetter != nil {
+		if err := e.cfg.deadLetter.Push(ctx, msg.Body); err != nil {
+			return err
+		}
+	}
+
+	return e.queue.Ack(ctx, msg.ID)
+}
+
+// MemoryQueue is Queue keeping messages in memory of the process. Zero value is ready to use.
+type MemoryQueue struct {
+	mu       sync.Mutex
+	seq      int
+	ready    []Message
+	inflight map[string]inflightMessage
+	notify   chan struct{}
+}
+
+type inflightMessage struct {
+	msg      Message
+	deadline time.Time
+}
+
+func (q *MemoryQueue) Push(ctx context.Context, body []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	q.ready = append(q.ready, Message{ID: strconv.Itoa(q.seq), Body: append([]byte(nil), body...)})
+	q.wake()
+
+	return nil
+}
+
+func (q *MemoryQueue) Pull(ctx context.Context, visibility time.Duration) (Message, error) {
+	for {
+		msg, ok, wait, notify := q.tryPull(visibility)
+		if ok {
+			return msg, nil
+		}
+
+		if err := q.wait(ctx, wait, notify); err != nil {
+			return Message{}, err
+		}
+	}
+}
+
+// wait blocks until d passes, notify is closed or ctx is done, zero d means no timeout.
+func (q *MemoryQueue) wait(ctx context.Context, d time.Duration, notify <-chan struct{}) error {
+	var timeout <-chan time.Time
+
+	if d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+
+		timeout = t.C
+	}
+
+	select {
+	case <-ctx.Done():
+		return cause(ctx)
+	case <-notify:
+		return nil
+	case <-timeout:
+		return nil
+	}
+}
+
+// tryPull returns the first visible message or time to wait until an in-flight message expires
+// and channel notifying about pushed messages.
+func (q *MemoryQueue) tryPull(visibility time.Duration) (msg Message, ok bool, wait time.Duration, notify <-chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+
+	for id, m := range q.inflight {
+		if !now.Before(m.deadline) {
+			delete(q.inflight, id)
+			q.ready = append(q.ready, m.msg)
+		} else if d := m.deadline.Sub(now); wait == 0 || d < wait {
+			wait = d
+		}
+	}
+
+	if q.notify == nil {
+		q.notify = make(chan struct{})
+	}
+
+	if len(q.ready) == 0 {
+		return msg, false, wait, q.notify
+	}
+
+	msg = q.ready[0]
+	q.ready = q.ready[1:]
+	msg.Attempt++
+
+	if q.inflight == nil {
+		q.inflight = map[string]inflightMessage{}
+	}
+
+	q.inflight[msg.ID] = inflightMessage{msg: msg, deadline: now.Add(visibility)}
+
+	return msg, true, 0, nil
+}
+
+func (q *MemoryQueue) Ack(ctx context.Context, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.inflight, id)
+
+	return nil
+}
+
+// Len returns number of messages waiting for delivery and being processed.
+func (q *MemoryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.ready) + len(q.inflight)
+}
+
+// wake notifies pulling consumers, must be called with locked mu.
+func (q *MemoryQueue) wake() {
+	if q.notify != nil {
+		close(q.notify)
+		q.notify = nil
+	}
+}
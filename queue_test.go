@@ -0,0 +1,45 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestQueueExecutorShutdown(t *testing.T) {
+	queue := &MemoryQueue{}
+	dead := &MemoryQueue{}
+
+	started := make(chan struct{}, 1)
+
+	wait := func(ctx context.Context, in int) (int, error) {
+		started <- struct{}{}
+		return blocking(ctx, in)
+	}
+
+	e := NewQueueExecutor(queue, JSONCodec[int](), Pipeline[int]{wait}, MaxAttempts(1), DeadLetter(dead))
+
+	if err := e.Enqueue(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	if err := e.Work(ctx, 1); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want context.Canceled", err)
+	}
+
+	if n := dead.Len(); n != 0 {
+		t.Fatalf("got %d dead letters, want none", n)
+	}
+
+	if n := queue.Len(); n != 1 {
+		t.Fatalf("got %d messages in the queue, want the interrupted one", n)
+	}
+
+}
@@ -0,0 +1,110 @@
+package pipe
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// RandSource is a seed of a run making Sample, Sampled, Shuffle and jitter of Retry backoffs reproducible,
+// attach it with WithRandSource. Every call of such a handler draws random numbers from its own source derived
+// from the seed, the handler, the Parallel batch and the number of the call of the handler within the batch,
+// so runs with the same seed and input make the same choices regardless of scheduling of batches.
+// Values sharing a handler in a stream stage with several workers still race for call numbers.
+// Handlers given an explicit source keep using it. RandSource is safe for concurrent use.
+type RandSource struct {
+	seed int64
+
+	mu    sync.Mutex
+	calls map[randKey]uint64
+}
+
+type randKey struct {
+	site  uint64
+	batch int
+}
+
+// randSites numbers handlers drawing random numbers in order of their construction.
+var randSites atomic.Uint64
+
+// NewRandSource returns source of runs seeded with seed.
+func NewRandSource(seed int64) *RandSource {
+	return &RandSource{seed: seed, calls: map[randKey]uint64{}}
+}
+
+// Seed returns the seed, log it to reproduce the run later.
+func (s *RandSource) Seed() int64 {
+	return s.seed
+}
+
+type randSourceKey struct{}
+
+// WithRandSource returns ctx making runs executed with it draw random numbers from src.
+func WithRandSource(ctx context.Context, src *RandSource) context.Context {
+	return context.WithValue(ctx, randSourceKey{}, src)
+}
+
+type batchKey struct{}
+
+// withBatch returns ctx of the Parallel batch with index i.
+func withBatch(ctx context.Context, i int) context.Context {
+	return context.WithValue(ctx, batchKey{}, i)
+}
+
+// newRandSite returns identifier of a handler drawing random numbers.
+func newRandSite() uint64 {
+	return randSites.Add(1)
+}
+
+// nextRand returns source for the next call of site, it is nil if ctx has no RandSource.
+func nextRand(ctx context.Context, site uint64) rand.Source {
+	s, _ := ctx.Value(randSourceKey{}).(*RandSource)
+	if s == nil {
+		return nil
+	}
+
+	batch, ok := ctx.Value(batchKey{}).(int)
+	if !ok {
+		batch = -1
+	}
+
+	k := randKey{site: site, batch: batch}
+
+	s.mu.Lock()
+	call := s.calls[k]
+	s.calls[k] = call + 1
+	s.mu.Unlock()
+
+	seed := mix(uint64(s.seed) ^ site)
+	seed = mix(seed ^ uint64(batch+1))
+	seed = mix(seed ^ call)
+
+	return &splitMix{state: seed}
+}
+
+// mix is the finalizer of SplitMix64.
+func mix(z uint64) uint64 {
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+
+	return z ^ (z >> 31)
+}
+
+// splitMix is SplitMix64 generator, it is cheap to create for every call of a handler.
+type splitMix struct {
+	state uint64
+}
+
+func (s *splitMix) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	return mix(s.state)
+}
+
+func (s *splitMix) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *splitMix) Seed(seed int64) {
+	s.state = uint64(seed)
+}
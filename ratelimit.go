@@ -0,0 +1,126 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimiter limits throughput shared by all stages using it, e.g. by every worker of a pipeline.
+// It is safe for concurrent use.
+type RateLimiter struct {
+	mu     sync.Mutex
+	bucket *tokenBucket
+}
+
+// NewRateLimiter returns limiter allowing rate units per second with bursts of up to burst units, at least one.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if rate <= 0 {
+		panic("rate value must be greater than zero!")
+	}
+
+	return &RateLimiter{bucket: newTokenBucket(rate, burst)}
+}
+
+// Wait waits until n units are allowed or ctx is done.
+// Requests larger than burst are allowed once the limiter has fully refilled, negative n is an error.
+func (l *RateLimiter) Wait(ctx context.Context, n int) error {
+	if n < 0 {
+		return fmt.Errorf("pipeline: rate limit: negative size %d", n)
+	}
+
+	for {
+		l.mu.Lock()
+		d := l.bucket.take(time.Now(), float64(n))
+		l.mu.Unlock()
+
+		if d == 0 {
+			return nil
+		}
+
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// RateLimit returns stage waiting for limiter before passing values further unchanged,
+// size returns the number of units a value costs, e.g. its length in bytes, nil size means one unit per value.
+// Negative size fails the stage.
+// Put it in front of stages calling shared downstream systems, the limit holds across all workers executing the pipeline.
+func RateLimit[T any](limiter *RateLimiter, size func(v T) int) HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		n := 1
+		if size != nil {
+			n = size(in)
+		}
+
+		if err := limiter.Wait(ctx, n); err != nil {
+			return out, err
+		}
+
+		return in, nil
+	}
+
+	return fn
+}
+
+// tokenBucket allows rate tokens per second with bursts of up to burst tokens, it is not safe for concurrent use.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns full bucket, zero rate means no limit and nil bucket.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if rate == 0 {
+		return nil
+	}
+
+	b := float64(max(burst, 1))
+
+	return &tokenBucket{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+// take withdraws n tokens and returns zero if there are enough of them,
+// otherwise it withdraws nothing and returns time until they are refilled.
+// Requests over burst are allowed when the bucket is full.
+func (b *tokenBucket) take(now time.Time, n float64) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.refill(now)
+
+	need := min(n, b.burst)
+
+	if b.tokens < need {
+		// A deficit refilled within a nanosecond must not be truncated to zero, which means allowed.
+		return max(time.Duration((need-b.tokens)/b.rate*float64(time.Second)), time.Nanosecond)
+	}
+
+	b.tokens -= n
+
+	return 0
+}
+
+// full reports whether the bucket has refilled up to burst.
+func (b *tokenBucket) full(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+
+	b.refill(now)
+
+	return b.tokens >= b.burst
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+}
@@ -0,0 +1,80 @@
+package pipe
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// bucketStep is a take of n tokens after at since the bucket has been created, want is the expected wait.
+type bucketStep struct {
+	at   time.Duration
+	n    float64
+	want time.Duration
+}
+
+func TestTokenBucket(t *testing.T) {
+	t0 := time.Now()
+
+	tests := []struct {
+		name   string
+		rate   float64
+		burst  float64
+		tokens float64
+		steps  []bucketStep
+	}{
+		{
+			name: "refill", rate: 10, burst: 2, tokens: 2,
+			steps: []bucketStep{
+				{at: 0, n: 1, want: 0},
+				{at: 0, n: 1, want: 0},
+				{at: 0, n: 1, want: 100 * time.Millisecond},
+				{at: 50 * time.Millisecond, n: 1, want: 50 * time.Millisecond},
+				{at: 100 * time.Millisecond, n: 1, want: 0},
+				// Requests over burst wait for the full bucket and leave debt.
+				{at: 100 * time.Millisecond, n: 5, want: 200 * time.Millisecond},
+				{at: 300 * time.Millisecond, n: 5, want: 0},
+				{at: 300 * time.Millisecond, n: 1, want: 400 * time.Millisecond},
+			},
+		},
+		{
+			name: "deficit under nanosecond", rate: 1e12, burst: 1, tokens: 0.4,
+			steps: []bucketStep{
+				{at: 0, n: 0.5, want: time.Nanosecond},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &tokenBucket{rate: tt.rate, burst: tt.burst, tokens: tt.tokens, last: t0}
+
+			for i, s := range tt.steps {
+				d := b.take(t0.Add(s.at), s.n)
+
+				// Float math may be off by a nanosecond, but a deficit must never mean no wait.
+				if diff := d - s.want; diff < -time.Nanosecond || diff > time.Nanosecond || (s.want > 0 && d == 0) {
+					t.Fatalf("step %d: got %s, want %s", i, d, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitNegative(t *testing.T) {
+	l := NewRateLimiter(1, 1)
+
+	if err := l.Wait(context.Background(), -1); err == nil {
+		t.Fatal("got nil error of negative size")
+	}
+
+	h := RateLimit(l, func(v int) int { return v })
+
+	if _, err := Execute(context.Background(), Pipeline[int]{h}, -5); err == nil {
+		t.Fatal("got nil error of negative size")
+	}
+
+	if out, err := Execute(context.Background(), Pipeline[int]{h}, 0); err != nil || out != 0 {
+		t.Fatalf("got %d, %v, want 0, nil", out, err)
+	}
+}
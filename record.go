@@ -0,0 +1,269 @@
+package pipe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// RecorderOption configures Recorder.
+type RecorderOption func(*recorderConfig)
+
+type recorderConfig struct {
+	stages bool
+}
+
+// RecordStages makes recorder persist outputs of every stage in addition to the input.
+func RecordStages() RecorderOption {
+	return func(c *recorderConfig) {
+		c.stages = true
+	}
+}
+
+// Recording is a recorded run of a pipeline.
+type Recording[T any] struct {
+	In T
+	// Outputs are outputs of the completed stages, it is empty unless RecordStages is set.
+	Outputs []T
+	// Err is the message of the run error, it is empty when the run has succeeded.
+	Err string
+}
+
+// Recorder persists inputs of pipeline runs to w, so they can be fed back through the pipeline by Replay.
+// Every run is written as a single length-prefixed frame after it has completed.
+type Recorder[T any] struct {
+	mu    sync.Mutex
+	w     io.Writer
+	codec Codec[T]
+	cfg   recorderConfig
+}
+
+// NewRecorder returns recorder serializing values to w with codec.
+func NewRecorder[T any](w io.Writer, codec Codec[T], opts ...RecorderOption) *Recorder[T] {
+	var cfg recorderConfig
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Recorder[T]{w: w, codec: codec, cfg: cfg}
+}
+
+// Wrap returns handler executing pipeline and recording its runs.
+// Values are encoded before they are passed to the next stage, so later mutations are not recorded.
+// Failure to record is returned by the handler. Runs stopped by ErrStop are recorded as succeeded.
+func (r *Recorder[T]) Wrap(pipeline Pipeline[T]) HandlerFunc[T] {
+	wrapped := pipeline
+
+	if r.cfg.stages {
+		wrapped = make(Pipeline[T], len(pipeline))
+
+		for i, handler := range pipeline {
+			wrapped[i] = r.stage(handler)
+		}
+	}
+
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		data, err := r.codec.Marshal(in)
+		if err != nil {
+			return out, fmt.Errorf("pipeline: record: %w", err)
+		}
+
+		rec := &recording{values: [][]byte{data}}
+
+		out, err = Execute(context.WithValue(ctx, recordingKey{}, rec), wrapped, in)
+
+		runErr := err
+		if errors.Is(err, ErrStop) {
+			runErr = nil
+		}
+
+		if werr := r.write(rec, runErr); werr != nil {
+			return out, errors.Join(err, werr)
+		}
+
+		return out, err
+	}
+
+	return describe(fn, describeInfo{middleware: "Record", inner: pipeline})
+}
+
+type recordingKey struct{}
+
+type recording struct {
+	mu     sync.Mutex
+	values [][]byte
+	err    error
+}
+
+func (r *Recorder[T]) stage(handler HandlerFunc[T]) HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		out, err = handler(ctx, in)
+		if err != nil && !finished(err) {
+			return out, err
+		}
+
+		rec, ok := ctx.Value(recordingKey{}).(*recording)
+		if !ok {
+			return out, err
+		}
+
+		data, merr := r.codec.Marshal(out)
+
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+
+		if merr != nil {
+			rec.err = errors.Join(rec.err, merr)
+		} else {
+			rec.values = append(rec.values, data)
+		}
+
+		return out, err
+	}
+
+	return describe(fn, describeInfo{middleware: "Record", inner: handler})
+}
+
+func (r *Recorder[T]) write(rec *recording, runErr error) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.err != nil {
+		return fmt.Errorf("pipeline: record: %w", rec.err)
+	}
+
+	var msg string
+
+	if runErr != nil {
+		msg = runErr.Error()
+	}
+
+	var body []byte
+
+	body = binary.AppendUvarint(body, uint64(len(rec.values)))
+
+	for _, data := range rec.values {
+		body = binary.AppendUvarint(body, uint64(len(data)))
+		body = append(body, data...)
+	}
+
+	body = binary.AppendUvarint(body, uint64(len(msg)))
+	body = append(body, msg...)
+
+	frame := binary.AppendUvarint(nil, uint64(len(body)))
+	frame = append(frame, body...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.w.Write(frame); err != nil {
+		return fmt.Errorf("pipeline: record: %w", err)
+	}
+
+	return nil
+}
+
+// Replay reads runs recorded by Recorder from r, executes pipeline for their inputs
+// and calls fn with every recording and the result of its new run.
+// Replay stops at the first error returned by fn.
+func Replay[T any](ctx context.Context, r io.Reader, codec Codec[T], pipeline Pipeline[T], fn func(rec Recording[T], out T, err error) error) error {
+	br := bufio.NewReader(r)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return cause(ctx)
+		}
+
+		rec, err := readRecording(br, codec)
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("pipeline: replay: %w", err)
+		}
+
+		out, err := Execute(ctx, pipeline, rec.In)
+
+		if err := fn(rec, out, err); err != nil {
+			return err
+		}
+	}
+}
+
+var errMalformedFrame = errors.New("malformed frame")
+
+func readRecording[T any](r *bufio.Reader, codec Codec[T]) (rec Recording[T], err error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return rec, err
+	}
+
+	// The size is read from the input, so the body grows with the data actually read instead of being allocated
+	// upfront, a corrupted size cannot exhaust memory.
+	var buf bytes.Buffer
+
+	if _, err := io.CopyN(&buf, r, int64(min(size, math.MaxInt64))); err != nil {
+		if err == io.EOF {
+			return rec, errMalformedFrame
+		}
+
+		return rec, err
+	}
+
+	body := buf.Bytes()
+
+	next := func() ([]byte, error) {
+		n, k := binary.Uvarint(body)
+		if k <= 0 || uint64(len(body)-k) < n {
+			return nil, errMalformedFrame
+		}
+
+		data := body[k : k+int(n)]
+		body = body[k+int(n):]
+
+		return data, nil
+	}
+
+	count, k := binary.Uvarint(body)
+	if k <= 0 || count == 0 {
+		return rec, errMalformedFrame
+	}
+
+	body = body[k:]
+
+	for i := uint64(0); i < count; i++ {
+		data, err := next()
+		if err != nil {
+			return rec, err
+		}
+
+		var v T
+
+		if err := codec.Unmarshal(data, &v); err != nil {
+			return rec, err
+		}
+
+		if i == 0 {
+			rec.In = v
+		} else {
+			rec.Outputs = append(rec.Outputs, v)
+		}
+	}
+
+	msg, err := next()
+	if err != nil {
+		return rec, err
+	}
+
+	rec.Err = string(msg)
+
+	return rec, nil
+}
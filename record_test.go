@@ -0,0 +1,61 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestReplayMalformed(t *testing.T) {
+	var valid bytes.Buffer
+
+	r := NewRecorder(&valid, JSONCodec[int]())
+	inc := func(ctx context.Context, in int) (int, error) { return in + 1, nil }
+
+	if _, err := Execute(context.Background(), Pipeline[int]{r.Wrap(Pipeline[int]{inc})}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	frame := valid.Bytes()
+
+	tests := []struct {
+		name  string
+		input []byte
+		runs  int
+		err   error
+	}{
+		{name: "valid", input: frame, runs: 1},
+		{name: "empty", input: nil},
+		{name: "huge size", input: binary.AppendUvarint(nil, 1<<62), err: errMalformedFrame},
+		{name: "truncated", input: frame[:len(frame)-1], err: errMalformedFrame},
+		{name: "no values", input: []byte{1, 0}, err: errMalformedFrame},
+		{name: "value overflow", input: []byte{3, 1, 100, 0}, err: errMalformedFrame},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := 0
+
+			fn := func(rec Recording[int], out int, err error) error {
+				runs++
+
+				if rec.In != 1 || out != 2 || err != nil {
+					t.Errorf("got %d, %d, %v, want 1, 2, nil", rec.In, out, err)
+				}
+
+				return nil
+			}
+
+			err := Replay(context.Background(), bytes.NewReader(tt.input), JSONCodec[int](), Pipeline[int]{inc}, fn)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			if runs != tt.runs {
+				t.Fatalf("got %d runs, want %d", runs, tt.runs)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownPipeline is wrapped by errors of Registry about pipelines which are not registered.
+var ErrUnknownPipeline = errors.New("pipeline: unknown pipeline")
+
+// DefaultRegistry is the registry served by cmd/pipe, register pipelines in it from init functions
+// of the packages linked into the command.
+var DefaultRegistry = &Registry{}
+
+// Registry maps names to pipelines executed over serialized batches,
+// so processes can refer to the same pipeline by name. Zero value is ready to use.
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]func(ctx context.Context, in []byte) ([]byte, error)
+}
+
+// Register adds pipeline to registry under name, values are serialized with codec.
+// It panics if the name is already taken.
+func Register[T any](r *Registry, name string, codec Codec[[]T], pipeline Pipeline[[]T]) {
+	fn := func(ctx context.Context, data []byte) ([]byte, error) {
+		var in []T
+
+		if err := codec.Unmarshal(data, &in); err != nil {
+			return nil, err
+		}
+
+		out, err := Execute(ctx, pipeline, in)
+		if err != nil && !errors.Is(err, ErrStop) {
+			return nil, err
+		}
+
+		data, merr := codec.Marshal(out)
+		if merr != nil {
+			return nil, merr
+		}
+
+		return data, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.entries[name]; ok {
+		panic("pipeline " + name + " is already registered!")
+	}
+
+	if r.entries == nil {
+		r.entries = map[string]func(ctx context.Context, in []byte) ([]byte, error){}
+	}
+
+	r.entries[name] = fn
+}
+
+// Execute runs pipeline registered by name over serialized batch.
+func (r *Registry) Execute(ctx context.Context, name string, batch []byte) ([]byte, error) {
+	r.mu.RLock()
+	fn, ok := r.entries[name]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPipeline, name)
+	}
+
+	return fn(ctx, batch)
+}
+
+// Names returns sorted names of registered pipelines.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.entries))
+
+	for name := range r.entries {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,230 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/WinPooh32/pipe/backoff"
+)
+
+// ErrRetryBudgetExhausted is wrapped together with the handler error when Retry has run out of budget.
+var ErrRetryBudgetExhausted = errors.New("pipeline: retry budget exhausted")
+
+// RetryOption configures Retry.
+type RetryOption func(*retryConfig)
+
+type retryConfig struct {
+	backoff  backoff.Backoff
+	classify func(err error) bool
+}
+
+// RetryDelay sets constant pause between attempts.
+func RetryDelay(d time.Duration) RetryOption {
+	if d < 0 {
+		panic("delay value must not be negative!")
+	}
+
+	return RetryBackoff(backoff.Constant(d))
+}
+
+// RetryBackoff sets strategy of pauses between attempts.
+func RetryBackoff(b backoff.Backoff) RetryOption {
+	return func(c *retryConfig) {
+		c.backoff = b
+	}
+}
+
+// RetryIf sets classifier of errors, Retry fails fast when it returns false.
+func RetryIf(classify func(err error) bool) RetryOption {
+	return func(c *retryConfig) {
+		c.classify = classify
+	}
+}
+
+// Retryable is implemented by errors knowing whether the failed call may succeed when it is retried.
+type Retryable interface {
+	Retryable() bool
+}
+
+// Permanent returns err marked as not retryable, Retry returns it without further attempts.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &permanentError{err: err}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+func (e *permanentError) Retryable() bool {
+	return false
+}
+
+// retryable reports whether the call failed with err may be retried: the error implementing Retryable
+// within its chain and the classifier must both allow it.
+func (c *retryConfig) retryable(err error) bool {
+	if finished(err) || errors.Is(err, ErrDropped) {
+		return false
+	}
+
+	var r Retryable
+	if errors.As(err, &r) && !r.Retryable() {
+		return false
+	}
+
+	return c.classify == nil || c.classify(err)
+}
+
+// Retry returns handler calling handler until it succeeds, up to attempts times in total.
+// Errors which are classified as permanent by Retryable or RetryIf are returned at once.
+// Every retry is withdrawn from the budget attached to ctx with WithRetryBudget if any.
+// Jitter of backoffs implementing backoff.Seeded is drawn from the RandSource of the run if any.
+func Retry[T any](handler HandlerFunc[T], attempts int, opts ...RetryOption) HandlerFunc[T] {
+	if attempts <= 0 {
+		panic("attempts value must be greater than zero!")
+	}
+
+	cfg := retryConfig{backoff: backoff.Constant(0)}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	site := newRandSite()
+
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		budget := retryBudgetFrom(ctx)
+		budget.request()
+
+		delays := cfg.backoff
+
+		if b, ok := delays.(backoff.Seeded); ok {
+			if src := nextRand(ctx, site); src != nil {
+				delays = b.Seed(src)
+			}
+		}
+
+		var delay time.Duration
+
+		for attempt := 1; ; attempt++ {
+			out, err = handler(ctx, in)
+			if err == nil || attempt == attempts || !cfg.retryable(err) {
+				return out, err
+			}
+
+			if !budget.withdraw() {
+				return out, fmt.Errorf("%w: %w", ErrRetryBudgetExhausted, err)
+			}
+
+			delay = delays.Next(attempt, delay)
+
+			if err := sleep(ctx, delay); err != nil {
+				return out, err
+			}
+		}
+	}
+
+	clone := func() any { return Retry(cloneHandler(handler), attempts, opts...) }
+
+	return describe(fn, describeInfo{middleware: fmt.Sprintf("Retry(attempts=%d)", attempts), inner: handler, clone: clone})
+}
+
+type retryBudgetKey struct{}
+
+// WithRetryBudget returns ctx making all Retry handlers of pipelines executed with it share budget.
+func WithRetryBudget(ctx context.Context, budget *RetryBudget) context.Context {
+	return context.WithValue(ctx, retryBudgetKey{}, budget)
+}
+
+func retryBudgetFrom(ctx context.Context) *RetryBudget {
+	b, _ := ctx.Value(retryBudgetKey{}).(*RetryBudget)
+	return b
+}
+
+// RetryBudget limits retries of several Retry handlers, so failures of a downstream service
+// do not multiply the load over it by retries of every stage.
+// Within a window it allows minRetries plus ratio of calls to be retried.
+// RetryBudget is safe for concurrent use, nil budget allows every retry.
+type RetryBudget struct {
+	ratio      float64
+	minRetries int
+	window     time.Duration
+
+	mu       sync.Mutex
+	start    time.Time
+	requests int
+	retries  int
+}
+
+// NewRetryBudget returns retry budget. Zero window never resets counters,
+// so NewRetryBudget(0, n, 0) allows n retries in total.
+func NewRetryBudget(ratio float64, minRetries int, window time.Duration) *RetryBudget {
+	if ratio < 0 || minRetries < 0 || window < 0 {
+		panic("budget values must not be negative!")
+	}
+
+	return &RetryBudget{
+		ratio:      ratio,
+		minRetries: minRetries,
+		window:     window,
+		start:      time.Now(),
+	}
+}
+
+func (b *RetryBudget) request() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll()
+	b.requests++
+}
+
+func (b *RetryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll()
+
+	if float64(b.retries) >= float64(b.minRetries)+b.ratio*float64(b.requests) {
+		return false
+	}
+
+	b.retries++
+
+	return true
+}
+
+// roll resets counters of the elapsed window, must be called with locked mu.
+func (b *RetryBudget) roll() {
+	if b.window == 0 {
+		return
+	}
+
+	if now := time.Now(); now.Sub(b.start) >= b.window {
+		b.start = now
+		b.requests = 0
+		b.retries = 0
+	}
+}
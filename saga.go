@@ -0,0 +1,31 @@
+package pipe
+
+import "context"
+
+// Compensate returns handler which registers compensate with the handler output after handler succeeds.
+// When a later stage of the same Execute call fails, registered compensations run in reverse order,
+// their errors are joined to the returned error. Compensations are called with ctx which is not canceled.
+// Handler returning ErrSkipRest or ErrStop has succeeded, its compensation is registered too.
+func Compensate[T any](handler HandlerFunc[T], compensate func(ctx context.Context, out T) error) HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		s := scopeFrom(ctx)
+		if s == nil || s.shared {
+			return out, errNoScope
+		}
+
+		out, err = handler(ctx, in)
+		if err != nil && !finished(err) {
+			return out, err
+		}
+
+		s.onFailure(func(ctx context.Context) error {
+			return compensate(ctx, out)
+		})
+
+		return out, err
+	}
+
+	clone := func() any { return Compensate(cloneHandler(handler), compensate) }
+
+	return describe(fn, describeInfo{middleware: "Compensate", inner: handler, clone: clone})
+}
@@ -0,0 +1,136 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// Sample returns handler passing every element of its input further with probability rate.
+// Random numbers are drawn from src, nil src means the RandSource of the run or the global source of math/rand.
+func Sample[T any](rate float64, src rand.Source) HandlerFunc[[]T] {
+	if rate < 0 || rate > 1 {
+		panic("rate value must be in range [0, 1]!")
+	}
+
+	random := randFor(src)
+
+	fn := func(ctx context.Context, in []T) (out []T, err error) {
+		r := random(ctx)
+		out = in[:0]
+
+		for _, v := range in {
+			if r.Float64() < rate {
+				out = append(out, v)
+			}
+		}
+
+		return out, nil
+	}
+
+	return fn
+}
+
+// Sampled returns handler calling handler with probability rate, the other values bypass it unchanged.
+// Use it to run expensive stages on a sample of values.
+func Sampled[T any](handler HandlerFunc[T], rate float64, src rand.Source) HandlerFunc[T] {
+	if rate < 0 || rate > 1 {
+		panic("rate value must be in range [0, 1]!")
+	}
+
+	random := randFor(src)
+
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		if random(ctx).Float64() >= rate {
+			return in, nil
+		}
+
+		return handler(ctx, in)
+	}
+
+	clone := func() any { return Sampled(cloneHandler(handler), rate, src) }
+
+	return describe(fn, describeInfo{middleware: fmt.Sprintf("Sampled(rate=%g)", rate), inner: handler, clone: clone})
+}
+
+// Shuffle returns handler permuting its input in place randomly.
+// Random numbers are drawn from src, nil src means the RandSource of the run or the global source of math/rand.
+// A source with fixed seed, e.g. rand.NewSource(1), makes the order deterministic.
+func Shuffle[T any](src rand.Source) HandlerFunc[[]T] {
+	random := randFor(src)
+
+	fn := func(ctx context.Context, in []T) (out []T, err error) {
+		random(ctx).Shuffle(len(in), func(i, j int) {
+			in[i], in[j] = in[j], in[i]
+		})
+
+		out = in
+
+		return out, nil
+	}
+
+	return fn
+}
+
+// randFor returns function giving random numbers for a call of a handler: drawn from src,
+// or from the RandSource of the run if src is nil.
+func randFor(src rand.Source) func(ctx context.Context) *lockedRand {
+	r := newLockedRand(src)
+
+	if src != nil {
+		return func(ctx context.Context) *lockedRand {
+			return r
+		}
+	}
+
+	site := newRandSite()
+
+	fn := func(ctx context.Context) *lockedRand {
+		if s := nextRand(ctx, site); s != nil {
+			return newLockedRand(s)
+		}
+
+		return r
+	}
+
+	return fn
+}
+
+// lockedRand is a source of random numbers safe for concurrent use.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// newLockedRand returns random numbers drawn from src, nil src means the global source of math/rand.
+func newLockedRand(src rand.Source) *lockedRand {
+	if src == nil {
+		return &lockedRand{}
+	}
+
+	return &lockedRand{r: rand.New(src)}
+}
+
+func (r *lockedRand) Float64() float64 {
+	if r.r == nil {
+		return rand.Float64()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.r.Float64()
+}
+
+func (r *lockedRand) Shuffle(n int, swap func(i, j int)) {
+	if r.r == nil {
+		rand.Shuffle(n, swap)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.r.Shuffle(n, swap)
+}
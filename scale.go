@@ -0,0 +1,109 @@
+package pipe
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// scaleDownTicks is the number of scaling intervals with empty input after which a scaled stage stops a worker.
+const scaleDownTicks = 3
+
+// scaleStage runs workers of the stage scaling their number between bounds of cfg until all of them have returned.
+func scaleStage[T any](ctx context.Context, cfg stageConfig, handler HandlerFunc[T], in <-chan item[T], worker func(handler HandlerFunc[T], quit <-chan struct{})) {
+	var latency atomic.Int64
+
+	fn := func(ctx context.Context, v T) (out T, err error) {
+		start := time.Now()
+		out, err = handler(ctx, v)
+		observeLatency(&latency, time.Since(start))
+
+		return out, err
+	}
+
+	timed := describe(fn, describeInfo{inner: handler})
+
+	quit := make(chan struct{})
+	exited := make(chan struct{})
+	workers := 0
+
+	start := func(n int) {
+		workers += n
+
+		for i := 0; i < n; i++ {
+			spawn(ctx, func() {
+				defer func() { exited <- struct{}{} }()
+
+				worker(timed, quit)
+			})
+		}
+	}
+
+	start(cfg.workers)
+
+	ticker := time.NewTicker(DefaultScaleInterval)
+	defer ticker.Stop()
+
+	idle := 0
+
+	for workers > 0 {
+		select {
+		case <-exited:
+			workers--
+
+		case <-ticker.C:
+			if ctx.Err() != nil {
+				continue
+			}
+
+			depth := len(in)
+
+			switch {
+			case depth > 0 && 2*depth >= cap(in) && workers < cfg.maxWorkers:
+				idle = 0
+
+				n := needed(depth, time.Duration(latency.Load()), DefaultScaleInterval) - workers
+				start(min(max(n, 1), cfg.maxWorkers-workers))
+
+			case depth == 0 && workers > cfg.workers:
+				if idle++; idle < scaleDownTicks {
+					continue
+				}
+
+				select {
+				case quit <- struct{}{}:
+					idle = 0
+				default:
+				}
+
+			default:
+				idle = 0
+			}
+		}
+	}
+}
+
+// needed returns number of workers draining depth values within interval given the latency of a value.
+func needed(depth int, latency, interval time.Duration) int {
+	if latency <= 0 {
+		return depth
+	}
+
+	return int((time.Duration(depth)*latency + interval - 1) / interval)
+}
+
+// observeLatency updates moving average of latency with d.
+func observeLatency(latency *atomic.Int64, d time.Duration) {
+	for {
+		old := latency.Load()
+
+		avg := int64(d)
+		if old > 0 {
+			avg = (7*old + int64(d)) / 8
+		}
+
+		if latency.CompareAndSwap(old, avg) {
+			return
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package pipe
+
+import (
+	"context"
+	"testing"
+)
+
+func TestScaleWorkersBuffer(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults []StageOption
+		opts     []StageOption
+		panics   bool
+	}{
+		{name: "default buffer", opts: []StageOption{ScaleWorkers(1, 4)}},
+		{name: "no buffer", opts: []StageOption{Buffer(0), ScaleWorkers(1, 4)}, panics: true},
+		{name: "no default buffer", defaults: []StageOption{Buffer(0)}, opts: []StageOption{ScaleWorkers(1, 4)}, panics: true},
+		{name: "fixed workers", opts: []StageOption{Buffer(0), ScaleWorkers(2, 2)}},
+		{name: "not scaled", opts: []StageOption{Buffer(0), Workers(2)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); (r != nil) != tt.panics {
+					t.Fatalf("got panic %v, want panic %t", r, tt.panics)
+				}
+			}()
+
+			inc := func(ctx context.Context, in int) (int, error) { return in + 1, nil }
+
+			s := NewStream[int](tt.defaults...).Via(inc, tt.opts...)
+
+			var sum int
+
+			sink := func(ctx context.Context, in int) error {
+				sum += in
+				return nil
+			}
+
+			if err := s.Run(context.Background(), FromSlice([]int{1, 2, 3}), sink); err != nil {
+				t.Fatal(err)
+			}
+
+			if sum != 9 {
+				t.Fatalf("got sum %d, want 9", sum)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var errNoScope = errors.New("pipeline: stage must be run by Execute")
+
+type (
+	scopeKey  struct{}
+	workerKey struct{}
+)
+
+// scope holds state of a single run shared between its handlers.
+type scope struct {
+	// shared is set when values of the run are processed concurrently, like in a stream.
+	shared bool
+	// cancel stops the run with a cause.
+	cancel func(cause error)
+	// stage is the index of the running stage of Execute.
+	stage atomic.Int32
+
+	mu            sync.Mutex
+	values        map[any]any
+	closers       []func() error
+	compensations []func(ctx context.Context) error
+}
+
+// newScope returns ctx carrying a fresh scope.
+func newScope(ctx context.Context, shared bool) (context.Context, *scope) {
+	s := &scope{shared: shared}
+	return context.WithValue(ctx, scopeKey{}, s), s
+}
+
+// scopeFrom returns scope of the current run or nil.
+func scopeFrom(ctx context.Context) *scope {
+	s, _ := ctx.Value(scopeKey{}).(*scope)
+	return s
+}
+
+// withWorker returns ctx carrying s as the scope of a long-living worker.
+func withWorker(ctx context.Context, s *scope) context.Context {
+	return context.WithValue(ctx, workerKey{}, s)
+}
+
+// stateScopeFrom returns scope keeping per-worker state of handlers:
+// the scope of the current worker or of the current run.
+func stateScopeFrom(ctx context.Context) *scope {
+	if s, ok := ctx.Value(workerKey{}).(*scope); ok {
+		return s
+	}
+
+	return scopeFrom(ctx)
+}
+
+func (s *scope) load(key any) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.values[key]
+
+	return v, ok
+}
+
+func (s *scope) store(key, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.values == nil {
+		s.values = map[any]any{}
+	}
+
+	s.values[key] = value
+}
+
+func (s *scope) delete(key any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.values, key)
+}
+
+// loadOrStore returns value stored by key or stores and returns result of newValue.
+func (s *scope) loadOrStore(key any, newValue func() any) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if v, ok := s.values[key]; ok {
+		return v
+	}
+
+	if s.values == nil {
+		s.values = map[any]any{}
+	}
+
+	v := newValue()
+	s.values[key] = v
+
+	return v
+}
+
+// onClose registers fn to be called when the scope is closed.
+func (s *scope) onClose(fn func() error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closers = append(s.closers, fn)
+}
+
+// onFailure registers fn to be called when the run fails.
+func (s *scope) onFailure(fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.compensations = append(s.compensations, fn)
+}
+
+// finish ends the run which has returned err: on failure it calls compensations in reverse order
+// with ctx detached from cancellation and joins their errors to err, then closes the scope.
+func (s *scope) finish(ctx context.Context, err error) error {
+	if err != nil {
+		s.mu.Lock()
+		compensations := s.compensations
+		s.compensations = nil
+		s.mu.Unlock()
+
+		errs := []error{err}
+		ctx := context.WithoutCancel(ctx)
+
+		for i := len(compensations) - 1; i >= 0; i-- {
+			if cerr := compensations[i](ctx); cerr != nil {
+				errs = append(errs, cerr)
+			}
+		}
+
+		if len(errs) > 1 {
+			err = errors.Join(errs...)
+		}
+	}
+
+	if cerr := s.close(); cerr != nil && err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// close calls registered functions in reverse order and returns the first error.
+func (s *scope) close() (err error) {
+	s.mu.Lock()
+	closers := s.closers
+	s.closers = nil
+	s.mu.Unlock()
+
+	for i := len(closers) - 1; i >= 0; i-- {
+		if cerr := closers[i](); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,35 @@
+//go:build go1.23
+
+package pipe
+
+import (
+	"context"
+	"iter"
+)
+
+// ExecuteSeq returns sequence of results of pipeline executed for every value of in, values are taken
+// from in lazily while the caller ranges over the results. Errors are yielded with their values
+// and do not stop the sequence. The sequence ends after a value for which a stage has returned ErrStop,
+// or with the cause of ctx when it is done.
+func ExecuteSeq[T any](ctx context.Context, pipeline Pipeline[T], in iter.Seq[T]) iter.Seq2[T, error] {
+	fn := func(yield func(T, error) bool) {
+		for v := range in {
+			if ctx.Err() != nil {
+				var zero T
+
+				yield(zero, cause(ctx))
+
+				return
+			}
+
+			stopped := false
+
+			out, err := execute(ctx, pipeline, nil, v, &stopped)
+			if !yield(out, err) || stopped {
+				return
+			}
+		}
+	}
+
+	return fn
+}
@@ -0,0 +1,78 @@
+package pipe
+
+import (
+	"context"
+	"sync"
+)
+
+// sharedKeys are keys of values of ctx of the first caller passed to the shared call of Singleflight.
+var sharedKeys = []any{executorKey{}, noRecoverKey{}, stackTracesKey{}, flagsKey{}, retryBudgetKey{}}
+
+// sharedContext returns context without cancellation holding values of ctx by sharedKeys.
+func sharedContext(ctx context.Context) context.Context {
+	shared := context.Background()
+
+	for _, k := range sharedKeys {
+		if v := ctx.Value(k); v != nil {
+			shared = context.WithValue(shared, k, v)
+		}
+	}
+
+	return shared
+}
+
+type flight[T any] struct {
+	done chan struct{}
+	out  T
+	err  error
+}
+
+// Singleflight returns handler which collapses concurrent calls with the same key into one call of handler,
+// all callers receive its output. The shared call is not canceled when its first caller gives up,
+// every caller waits for it only until its own ctx is done. The shared call belongs to no caller, its ctx holds
+// only the executor and the settings of panics, stack traces, flags and retry budget of the first caller,
+// but not its run scope, metadata, hooks or correlation ID.
+// The output is shared by callers, so it must not be modified if T is a reference type.
+func Singleflight[T any, K comparable](handler HandlerFunc[T], key func(T) K) HandlerFunc[T] {
+	var (
+		mu      sync.Mutex
+		flights = map[K]*flight[T]{}
+	)
+
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		k := key(in)
+
+		mu.Lock()
+
+		f, ok := flights[k]
+		if !ok {
+			f = &flight[T]{done: make(chan struct{})}
+			flights[k] = f
+
+			detached := sharedContext(ctx)
+
+			spawn(ctx, func() {
+				f.out, f.err = call(detached, handler, in)
+
+				mu.Lock()
+				delete(flights, k)
+				mu.Unlock()
+
+				close(f.done)
+			})
+		}
+
+		mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return out, cause(ctx)
+		case <-f.done:
+			return f.out, f.err
+		}
+	}
+
+	clone := func() any { return Singleflight(cloneHandler(handler), key) }
+
+	return describe(fn, describeInfo{middleware: "Singleflight", inner: handler, clone: clone})
+}
@@ -0,0 +1,49 @@
+package pipe
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSingleflightContext(t *testing.T) {
+	exec := NewBoundedExecutor(2, 0)
+	defer exec.Close()
+
+	var leaked []string
+
+	h := func(ctx context.Context, in int) (int, error) {
+		checks := []struct {
+			name   string
+			leaked bool
+		}{
+			{name: "scope", leaked: scopeFrom(ctx) != nil},
+			{name: "metadata", leaked: MetadataFrom(ctx) != nil},
+			{name: "hook", leaked: hookFrom(ctx) != nil},
+			{name: "correlation ID", leaked: CorrelationID(ctx) != ""},
+			{name: "lost executor", leaked: executorFrom(ctx) != exec},
+			{name: "lost recovery setting", leaked: recovering(ctx)},
+		}
+
+		for _, c := range checks {
+			if c.leaked {
+				leaked = append(leaked, c.name)
+			}
+		}
+
+		return in * 2, nil
+	}
+
+	ctx := WithHook(context.Background(), func(ctx context.Context, e Event) {})
+	ctx = WithoutRecover(WithExecutor(ctx, exec))
+
+	sf := Singleflight(h, func(v int) int { return v })
+
+	out, err := Execute(ctx, Pipeline[int]{Correlate[int](nil), sf}, 3)
+	if err != nil || out != 6 {
+		t.Fatalf("got %d, %v, want 6, nil", out, err)
+	}
+
+	if len(leaked) > 0 {
+		t.Fatalf("the shared call has got wrong values of the caller ctx: %v", leaked)
+	}
+}
@@ -0,0 +1,103 @@
+package pipe
+
+import (
+	"context"
+)
+
+// Skip returns handler dropping the first n elements of its input.
+func Skip[T any](n int) HandlerFunc[[]T] {
+	if n < 0 {
+		panic("n value must not be negative!")
+	}
+
+	fn := func(ctx context.Context, in []T) (out []T, err error) {
+		if n > len(in) {
+			out = in[len(in):]
+			return out, nil
+		}
+
+		out = in[n:]
+
+		return out, nil
+	}
+
+	return fn
+}
+
+// SkipWhile returns handler dropping leading elements of its input while pred is true.
+func SkipWhile[T any](pred func(v T) bool) HandlerFunc[[]T] {
+	fn := func(ctx context.Context, in []T) (out []T, err error) {
+		i := 0
+
+		for i < len(in) && pred(in[i]) {
+			i++
+		}
+
+		out = in[i:]
+
+		return out, nil
+	}
+
+	return fn
+}
+
+// SkipSource returns source emitting values of src except the first n.
+func SkipSource[T any](src Source[T], n int) Source[T] {
+	if n < 0 {
+		panic("n value must not be negative!")
+	}
+
+	return skipSource(src, func() func(T) bool {
+		skipped := 0
+
+		return func(T) bool {
+			if skipped < n {
+				skipped++
+				return true
+			}
+
+			return false
+		}
+	})
+}
+
+// SkipWhileSource returns source emitting values of src starting from the first one for which pred is false.
+func SkipWhileSource[T any](src Source[T], pred func(v T) bool) Source[T] {
+	return skipSource(src, func() func(T) bool { return pred })
+}
+
+// skipSource drops leading values of src while skip is true, newSkip is called on every run.
+func skipSource[T any](src Source[T], newSkip func() func(v T) bool) Source[T] {
+	fn := func(ctx context.Context, out chan<- T) error {
+		skip := newSkip()
+
+		return forward(ctx, src, func(ctx context.Context, values <-chan T) error {
+			skipping := true
+
+			for {
+				select {
+				case <-ctx.Done():
+					return cause(ctx)
+				case v, ok := <-values:
+					if !ok {
+						return nil
+					}
+
+					if skipping && skip(v) {
+						continue
+					}
+
+					skipping = false
+
+					select {
+					case <-ctx.Done():
+						return cause(ctx)
+					case out <- v:
+					}
+				}
+			}
+		})
+	}
+
+	return fn
+}
@@ -0,0 +1,173 @@
+package pipe
+
+import (
+	"context"
+	"time"
+)
+
+// PageSource returns source pulling pages of values with fetch lazily, only one page is kept in memory.
+// The first page is fetched with empty cursor, the following ones with the cursor returned by the previous call.
+// Empty next cursor means the last page.
+func PageSource[T any](fetch func(ctx context.Context, cursor string) (items []T, next string, err error)) Source[T] {
+	fn := func(ctx context.Context, out chan<- T) error {
+		cursor := ""
+
+		for {
+			items, next, err := fetch(ctx, cursor)
+			if err != nil {
+				return err
+			}
+
+			for _, v := range items {
+				select {
+				case <-ctx.Done():
+					return cause(ctx)
+				case out <- v:
+				}
+			}
+
+			if next == "" {
+				return nil
+			}
+
+			cursor = next
+		}
+	}
+
+	return fn
+}
+
+// Generate returns source emitting values returned by next until it reports false or fails.
+func Generate[T any](next func(ctx context.Context) (v T, ok bool, err error)) Source[T] {
+	fn := func(ctx context.Context, out chan<- T) error {
+		for {
+			v, ok, err := next(ctx)
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				return nil
+			}
+
+			select {
+			case <-ctx.Done():
+				return cause(ctx)
+			case out <- v:
+			}
+		}
+	}
+
+	return fn
+}
+
+// Tick returns source emitting current time every interval until ctx is done.
+// Ticks are dropped while the stream is not ready to receive them.
+func Tick(interval time.Duration) Source[time.Time] {
+	return Poll(interval, func(ctx context.Context, t time.Time) (time.Time, error) {
+		return t, nil
+	})
+}
+
+// Poll returns source emitting results of fetch called every interval until ctx is done or fetch fails.
+func Poll[T any](interval time.Duration, fetch func(ctx context.Context, t time.Time) (T, error)) Source[T] {
+	if interval <= 0 {
+		panic("interval value must be greater than zero!")
+	}
+
+	fn := func(ctx context.Context, out chan<- T) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return cause(ctx)
+			case t := <-ticker.C:
+				v, err := fetch(ctx, t)
+				if err != nil {
+					return err
+				}
+
+				select {
+				case <-ctx.Done():
+					return cause(ctx)
+				case out <- v:
+				}
+			}
+		}
+	}
+
+	return fn
+}
+
+// Chunk returns source grouping values of src into batches of size values for streams of []T.
+// A partial batch is emitted when its oldest value has waited for maxWait, zero maxWait means wait until the batch
+// is full. The rest of values is emitted when src is exhausted.
+func Chunk[T any](src Source[T], size int, maxWait time.Duration) Source[[]T] {
+	if size <= 0 {
+		panic("size value must be greater than zero!")
+	}
+
+	if maxWait < 0 {
+		panic("wait value must not be negative!")
+	}
+
+	fn := func(ctx context.Context, out chan<- []T) error {
+		consume := func(ctx context.Context, values <-chan T) error {
+			var (
+				batch   []T
+				timer   *time.Timer
+				timeout <-chan time.Time
+			)
+
+			emit := func() error {
+				if timer != nil {
+					timer.Stop()
+					timer, timeout = nil, nil
+				}
+
+				if len(batch) == 0 {
+					return nil
+				}
+
+				select {
+				case <-ctx.Done():
+					return cause(ctx)
+				case out <- batch:
+					batch = nil
+					return nil
+				}
+			}
+
+			for {
+				select {
+				case v, ok := <-values:
+					if !ok {
+						return emit()
+					}
+
+					batch = append(batch, v)
+
+					if len(batch) == 1 && maxWait > 0 {
+						timer = time.NewTimer(maxWait)
+						timeout = timer.C
+					}
+
+					if len(batch) < size {
+						continue
+					}
+				case <-timeout:
+				}
+
+				if err := emit(); err != nil {
+					return err
+				}
+			}
+		}
+
+		return forward(ctx, src, consume)
+	}
+
+	return fn
+}
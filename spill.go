@@ -0,0 +1,229 @@
+package pipe
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Spill is an append-only sequence of values which keeps up to limit values in memory
+// and moves the rest into a temporary file via codec.
+// Spill is not safe for concurrent use.
+type Spill[T any] struct {
+	codec Codec[T]
+	limit int
+	dir   string
+
+	mem  []T
+	file *os.File
+	w    *bufio.Writer
+	size int64
+	n    int
+}
+
+// NewSpill returns empty spill keeping at most limit values in memory.
+// Temporary files are created in dir, os.TempDir is used when dir is empty.
+func NewSpill[T any](codec Codec[T], limit int, dir string) *Spill[T] {
+	if limit <= 0 {
+		panic("limit value must be greater than zero!")
+	}
+
+	return &Spill[T]{codec: codec, limit: limit, dir: dir}
+}
+
+// Len returns number of values in the spill.
+func (s *Spill[T]) Len() int {
+	return s.n + len(s.mem)
+}
+
+// InFile returns number of values moved into the file.
+func (s *Spill[T]) InFile() int {
+	return s.n
+}
+
+// Append adds values to the end of the spill.
+func (s *Spill[T]) Append(values ...T) error {
+	for _, v := range values {
+		if len(s.mem) == s.limit {
+			if err := s.flush(); err != nil {
+				return err
+			}
+		}
+
+		s.mem = append(s.mem, v)
+	}
+
+	return nil
+}
+
+func (s *Spill[T]) flush() error {
+	if s.file == nil {
+		f, err := os.CreateTemp(s.dir, "pipe-spill-*")
+		if err != nil {
+			return err
+		}
+
+		s.file = f
+		s.w = bufio.NewWriter(f)
+	}
+
+	var lenBuf [binary.MaxVarintLen64]byte
+
+	for _, v := range s.mem {
+		data, err := s.codec.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		n := binary.PutUvarint(lenBuf[:], uint64(len(data)))
+
+		if _, err := s.w.Write(lenBuf[:n]); err != nil {
+			return err
+		}
+
+		if _, err := s.w.Write(data); err != nil {
+			return err
+		}
+
+		s.size += int64(n + len(data))
+	}
+
+	s.n += len(s.mem)
+	s.mem = s.mem[:0]
+
+	return nil
+}
+
+// Each calls fn with consecutive chunks of at most size values in order of appending.
+// Spilled values are read back from the file, so only one chunk of them is in memory at a time.
+// fn must not retain the chunk.
+func (s *Spill[T]) Each(ctx context.Context, size int, fn func(chunk []T) error) error {
+	if size <= 0 {
+		panic("size value must be greater than zero!")
+	}
+
+	if s.file != nil {
+		if err := s.w.Flush(); err != nil {
+			return err
+		}
+
+		if err := s.readFile(ctx, size, fn); err != nil {
+			return err
+		}
+	}
+
+	for beg := 0; beg < len(s.mem); beg += size {
+		if err := ctx.Err(); err != nil {
+			return cause(ctx)
+		}
+
+		end := beg + size
+		if end > len(s.mem) {
+			end = len(s.mem)
+		}
+
+		if err := fn(s.mem[beg:end]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Spill[T]) readFile(ctx context.Context, size int, fn func(chunk []T) error) error {
+	r := bufio.NewReader(io.NewSectionReader(s.file, 0, s.size))
+	chunk := make([]T, 0, size)
+
+	var data []byte
+
+	for i := 0; i < s.n; i++ {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+
+		if uint64(cap(data)) < n {
+			data = make([]byte, n)
+		}
+
+		data = data[:n]
+
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+
+		var v T
+
+		if err := s.codec.Unmarshal(data, &v); err != nil {
+			return err
+		}
+
+		chunk = append(chunk, v)
+
+		if len(chunk) == size || i == s.n-1 {
+			if err := ctx.Err(); err != nil {
+				return cause(ctx)
+			}
+
+			if err := fn(chunk); err != nil {
+				return err
+			}
+
+			chunk = chunk[:0]
+		}
+	}
+
+	return nil
+}
+
+// Close releases values and removes the temporary file.
+func (s *Spill[T]) Close() error {
+	s.mem = nil
+	s.n = 0
+	s.size = 0
+
+	if s.file == nil {
+		return nil
+	}
+
+	f := s.file
+	s.file = nil
+	s.w = nil
+
+	return errors.Join(f.Close(), os.Remove(f.Name()))
+}
+
+// Spilled returns handler over spill which applies handler to chunks of at most size values
+// and collects results into a new spill with the same codec, limit and directory.
+// The input spill is closed, so peak memory of a pipeline of such stages stays within limit and chunk size
+// no matter how many values pass through it.
+func Spilled[T any](handler HandlerFunc[[]T], size int) HandlerFunc[*Spill[T]] {
+	fn := func(ctx context.Context, in *Spill[T]) (out *Spill[T], err error) {
+		out = NewSpill(in.codec, in.limit, in.dir)
+
+		err = in.Each(ctx, size, func(chunk []T) error {
+			res, err := handler(ctx, chunk)
+			if err != nil {
+				return err
+			}
+
+			return out.Append(res...)
+		})
+
+		if cerr := in.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+
+		if err != nil {
+			return nil, errors.Join(err, out.Close())
+		}
+
+		return out, nil
+	}
+
+	return describe(fn, describeInfo{middleware: fmt.Sprintf("Spilled(size=%d)", size), inner: handler})
+}
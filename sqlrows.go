@@ -0,0 +1,59 @@
+package pipe
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Queryer runs queries, it is implemented by *sql.DB, *sql.Tx and *sql.Conn.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// ScanFunc converts the current row into a record.
+type ScanFunc[T any] func(rows *sql.Rows) (T, error)
+
+// Rows returns source emitting records scanned from rows. Rows are closed when the source returns,
+// errors of scan, iteration and Close are returned by the source. It can be run only once.
+func Rows[T any](rows *sql.Rows, scan ScanFunc[T]) Source[T] {
+	fn := func(ctx context.Context, out chan<- T) (err error) {
+		defer func() {
+			if cerr := rows.Close(); cerr != nil {
+				err = errors.Join(err, cerr)
+			}
+		}()
+
+		for rows.Next() {
+			v, err := scan(rows)
+			if err != nil {
+				return err
+			}
+
+			select {
+			case <-ctx.Done():
+				return cause(ctx)
+			case out <- v:
+			}
+		}
+
+		return rows.Err()
+	}
+
+	return fn
+}
+
+// QuerySource returns source running query with args on every start and emitting records scanned from its rows.
+// Feed it to Stream or to ExecuteSource processing records in chunks.
+func QuerySource[T any](db Queryer, scan ScanFunc[T], query string, args ...any) Source[T] {
+	fn := func(ctx context.Context, out chan<- T) error {
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		return Rows(rows, scan)(ctx, out)
+	}
+
+	return fn
+}
@@ -0,0 +1,62 @@
+package pipe
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+type txKey struct{}
+
+// TxBeginner starts transactions, it is implemented by *sql.DB and *sql.Conn.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// InTx returns handler executing pipeline inside a transaction of db.
+// The transaction is available to the stages with TxFrom. It is committed when pipeline succeeds
+// and rolled back when it fails or panics, also when panics are not recovered, see WithoutRecover.
+// A stage stopping the enclosing run with ErrStop commits the transaction.
+func InTx[T any](db TxBeginner, opts *sql.TxOptions, pipeline Pipeline[T]) HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		tx, err := db.BeginTx(ctx, opts)
+		if err != nil {
+			return out, err
+		}
+
+		committed := false
+
+		defer func() {
+			if committed {
+				return
+			}
+
+			if rerr := tx.Rollback(); rerr != nil && err != nil {
+				err = errors.Join(err, rerr)
+			}
+		}()
+
+		out, err = Execute(context.WithValue(ctx, txKey{}, tx), pipeline, in)
+		if err != nil && !errors.Is(err, ErrStop) {
+			return out, err
+		}
+
+		committed = true
+
+		if cerr := tx.Commit(); cerr != nil {
+			return out, cerr
+		}
+
+		return out, err
+	}
+
+	clone := func() any { return InTx(db, opts, pipeline.Clone()) }
+
+	return describe(fn, describeInfo{middleware: "InTx", inner: pipeline, clone: clone})
+}
+
+// TxFrom returns transaction started by InTx or nil.
+func TxFrom(ctx context.Context) *sql.Tx {
+	tx, _ := ctx.Value(txKey{}).(*sql.Tx)
+	return tx
+}
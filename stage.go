@@ -0,0 +1,86 @@
+package pipe
+
+import (
+	"context"
+	"sync"
+)
+
+// Stage is a handler owning resources which must be set up before the first value and released after the last one.
+type Stage[T any] interface {
+	// Init is called once per run before the first call of Handle.
+	Init(ctx context.Context) error
+	// Handle processes a value.
+	Handle(ctx context.Context, in T) (out T, err error)
+	// Close is called once per run after the last call of Handle if Init succeeded.
+	Close() error
+}
+
+type stageState struct {
+	once sync.Once
+	err  error
+}
+
+// FromStage returns handler over stage.
+// Stage is initialized when the handler is reached for the first time during Execute
+// or by a worker of a Stream stage or Pool, and closed when Execute returns or the worker stops.
+// Closers of several stages are called in reverse order of initialization.
+// Close error is returned by Execute unless it has failed before.
+// Outside of them the stage is initialized and closed around every call.
+func FromStage[T any](stage Stage[T]) HandlerFunc[T] {
+	key := new(struct{ byte })
+
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		s := stateScopeFrom(ctx)
+		if s == nil {
+			if err := stage.Init(ctx); err != nil {
+				return out, err
+			}
+
+			out, err = stage.Handle(ctx, in)
+			if cerr := stage.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+
+			return out, err
+		}
+
+		state := s.loadOrStore(key, func() any { return &stageState{} }).(*stageState)
+
+		state.once.Do(func() {
+			state.err = stage.Init(ctx)
+			if state.err == nil {
+				s.onClose(stage.Close)
+			}
+		})
+
+		if state.err != nil {
+			return out, state.err
+		}
+
+		return stage.Handle(ctx, in)
+	}
+
+	return describe(fn, describeInfo{middleware: "FromStage", inner: stage})
+}
+
+// PerWorker returns handler which creates its own instance of the handler with factory
+// for every worker: once per Execute call, so every batch of Parallel gets a fresh instance,
+// and once per worker of a Stream stage or Pool.
+// Use it for handlers with internal state which is not safe for concurrent use.
+// Outside of Execute a new instance is created for every call.
+func PerWorker[T any](factory func() HandlerFunc[T]) HandlerFunc[T] {
+	key := new(struct{ byte })
+
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		s := stateScopeFrom(ctx)
+		if s == nil {
+			return factory()(ctx, in)
+		}
+
+		handler := s.loadOrStore(key, func() any { return factory() }).(HandlerFunc[T])
+
+		return handler(ctx, in)
+	}
+
+	return describe(fn, describeInfo{middleware: "PerWorker", inner: factory})
+}
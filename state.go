@@ -0,0 +1,54 @@
+package pipe
+
+import (
+	"context"
+	"sync"
+)
+
+// StateStore keeps state which must outlive a single run, like processed keys or checkpoints.
+// Implementations backed by databases let several processes share the state.
+type StateStore interface {
+	// Load returns value stored by key, ok is false when there is no such key.
+	Load(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Store saves value by key.
+	Store(ctx context.Context, key string, value []byte) error
+	// Delete removes key.
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryStore is StateStore keeping values in memory. Zero value is ready to use.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	values map[string][]byte
+}
+
+func (s *MemoryStore) Load(ctx context.Context, key string) (value []byte, ok bool, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok = s.values[key]
+
+	return value, ok, nil
+}
+
+func (s *MemoryStore) Store(ctx context.Context, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.values == nil {
+		s.values = map[string][]byte{}
+	}
+
+	s.values[key] = append([]byte(nil), value...)
+
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.values, key)
+
+	return nil
+}
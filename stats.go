@@ -0,0 +1,128 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// StageStats are counters of a stage.
+type StageStats struct {
+	Stage int
+	// Count is the number of calls of the stage, Failed is the number of its failures.
+	Count  int64
+	Failed int64
+	// Total is the time all calls have taken, Max is the time of the slowest one.
+	Total time.Duration
+	Max   time.Duration
+}
+
+// Mean returns average time of a call.
+func (s StageStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+
+	return s.Total / time.Duration(s.Count)
+}
+
+// PipelineStats is a snapshot of counters of a pipeline.
+type PipelineStats struct {
+	// Processed is the number of runs, Failed is the number of failed ones.
+	Processed int64
+	Failed    int64
+	Stages    []StageStats
+}
+
+// pipelineStats counts runs and stage calls of a pipeline, it is safe for concurrent use.
+type pipelineStats struct {
+	processed atomic.Int64
+	failed    atomic.Int64
+	stages    []stageCounters
+}
+
+type stageCounters struct {
+	count  atomic.Int64
+	failed atomic.Int64
+	total  atomic.Int64
+	max    atomic.Int64
+}
+
+// instrument returns stats and copy of pipeline which stages update them.
+func instrument[T any](pipeline Pipeline[T]) (*pipelineStats, Pipeline[T]) {
+	s := &pipelineStats{stages: make([]stageCounters, len(pipeline))}
+	p := make(Pipeline[T], len(pipeline))
+
+	for i, handler := range pipeline {
+		p[i] = timed(&s.stages[i], handler)
+	}
+
+	return s, p
+}
+
+// timed returns handler accounting calls of handler to c.
+func timed[T any](c *stageCounters, handler HandlerFunc[T]) HandlerFunc[T] {
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		start := time.Now()
+		returned := false
+
+		defer func() {
+			c.observe(time.Since(start), !returned || (err != nil && !finished(err) && !errors.Is(err, ErrDropped)))
+		}()
+
+		out, err = handler(ctx, in)
+		returned = true
+
+		return out, err
+	}
+
+	return describe(fn, describeInfo{inner: handler})
+}
+
+// observe accounts the result of a run.
+func (s *pipelineStats) observe(err error) {
+	s.processed.Add(1)
+
+	if err != nil {
+		s.failed.Add(1)
+	}
+}
+
+func (s *pipelineStats) snapshot() PipelineStats {
+	stats := PipelineStats{
+		Processed: s.processed.Load(),
+		Failed:    s.failed.Load(),
+		Stages:    make([]StageStats, len(s.stages)),
+	}
+
+	for i := range s.stages {
+		c := &s.stages[i]
+
+		stats.Stages[i] = StageStats{
+			Stage:  i,
+			Count:  c.count.Load(),
+			Failed: c.failed.Load(),
+			Total:  time.Duration(c.total.Load()),
+			Max:    time.Duration(c.max.Load()),
+		}
+	}
+
+	return stats
+}
+
+func (c *stageCounters) observe(d time.Duration, failed bool) {
+	c.count.Add(1)
+	c.total.Add(int64(d))
+
+	if failed {
+		c.failed.Add(1)
+	}
+
+	for {
+		max := c.max.Load()
+		if int64(d) <= max || c.max.CompareAndSwap(max, int64(d)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stopOn returns stage stopping the run after batches holding v.
+func stopOn(v int) HandlerFunc[[]int] {
+	fn := func(ctx context.Context, in []int) (out []int, err error) {
+		if slices.Contains(in, v) {
+			return in, ErrStop
+		}
+
+		return in, nil
+	}
+
+	return fn
+}
+
+// nested returns stage running fn as a nested run and counting calls of the stage following it.
+func nested(fn HandlerFunc[[]int], calls *atomic.Int32) Pipeline[[]int] {
+	next := func(ctx context.Context, in []int) ([]int, error) {
+		calls.Add(1)
+		return in, nil
+	}
+
+	return Pipeline[[]int]{fn, next}
+}
+
+func TestStopNested(t *testing.T) {
+	in := []int{0, 1, 2, 3, 4, 5}
+
+	chunks := func(ctx context.Context, in []int) (out []int, err error) {
+		return ExecuteChunked(ctx, Pipeline[[]int]{stopOn(2)}, in, 2)
+	}
+
+	registry := &Registry{}
+	Register(registry, "stop", JSONCodec[[]int](), Pipeline[[]int]{stopOn(2)})
+
+	registered := func(ctx context.Context, in []int) (out []int, err error) {
+		data, err := registry.Execute(ctx, "stop", must(JSONCodec[[]int]().Marshal(in)))
+		if data != nil {
+			if uerr := JSONCodec[[]int]().Unmarshal(data, &out); uerr != nil {
+				return nil, uerr
+			}
+		}
+
+		return out, err
+	}
+
+	tests := []struct {
+		name string
+		fn   HandlerFunc[[]int]
+		want []int
+	}{
+		{
+			name: "Parallel",
+			fn: func(ctx context.Context, in []int) ([]int, error) {
+				return Parallel(ctx, Pipeline[[]int]{stopOn(2)}, in, len(in), MaxInFlight(1))
+			},
+			want: []int{0, 1, 2},
+		},
+		{
+			name: "Checkpoint",
+			fn: func(ctx context.Context, in []int) ([]int, error) {
+				return Parallel(ctx, Pipeline[[]int]{stopOn(2)}, in, len(in), MaxInFlight(1),
+					Checkpoint(&MemoryStore{}, "stop", JSONCodec[[]int]()))
+			},
+			want: []int{0, 1, 2},
+		},
+		{name: "ExecuteChunked", fn: chunks, want: []int{0, 1, 2, 3}},
+		{name: "Register", fn: registered, want: in},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+
+			out, err := Execute(context.Background(), nested(tt.fn, &calls), slices.Clone(in))
+			if err != nil {
+				t.Fatalf("got error %v, want nil", err)
+			}
+
+			if !slices.Equal(out, tt.want) {
+				t.Fatalf("got output %v, want %v", out, tt.want)
+			}
+
+			if n := calls.Load(); n != 0 {
+				t.Fatalf("the stage after the stopped run has been called %d times, want 0", n)
+			}
+		})
+	}
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
+func TestParallelStop(t *testing.T) {
+	defer checkLeaks(t)()
+
+	// Batches after the stopping one block until the stop cancels them.
+	h := func(ctx context.Context, in []int) ([]int, error) {
+		if in[0] == 0 {
+			return in, ErrStop
+		}
+
+		return blocking(ctx, in)
+	}
+
+	out, err := Parallel(context.Background(), Pipeline[[]int]{h}, []int{0, 1, 2, 3}, 4)
+	if err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	if want := []int{0}; !slices.Equal(out, want) {
+		t.Fatalf("got output %v, want %v", out, want)
+	}
+}
+
+func TestExecuteSourceStop(t *testing.T) {
+	defer checkLeaks(t)()
+
+	var chunks [][]int
+
+	sink := func(ctx context.Context, in []int) error {
+		chunks = append(chunks, in)
+		return nil
+	}
+
+	if err := ExecuteSource(context.Background(), Pipeline[[]int]{stopOn(5)}, endless, 2, sink); err != nil {
+		t.Fatalf("got error %v, want nil", err)
+	}
+
+	want := [][]int{{0, 1}, {2, 3}, {4, 5}}
+
+	if !slices.EqualFunc(chunks, want, slices.Equal[[]int]) {
+		t.Fatalf("got chunks %v, want %v", chunks, want)
+	}
+}
+
+func TestRecorderStop(t *testing.T) {
+	var buf bytes.Buffer
+
+	r := NewRecorder(&buf, JSONCodec[int](), RecordStages())
+
+	stop := func(ctx context.Context, in int) (int, error) { return in + 1, ErrStop }
+	inc := func(ctx context.Context, in int) (int, error) { return in + 1, nil }
+
+	var calls atomic.Int32
+
+	next := func(ctx context.Context, in int) (int, error) {
+		calls.Add(1)
+		return in, nil
+	}
+
+	out, err := Execute(context.Background(), Pipeline[int]{r.Wrap(Pipeline[int]{inc, stop, inc}), next}, 1)
+	if err != nil || out != 3 {
+		t.Fatalf("got %d, %v, want 3, nil", out, err)
+	}
+
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("the stage after the stopped run has been called %d times, want 0", n)
+	}
+
+	var recs []Recording[int]
+
+	collect := func(rec Recording[int], out int, err error) error {
+		recs = append(recs, rec)
+		return nil
+	}
+
+	if err := Replay(context.Background(), &buf, JSONCodec[int](), Pipeline[int]{inc}, collect); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recs) != 1 {
+		t.Fatalf("got %d recordings, want 1", len(recs))
+	}
+
+	if recs[0].Err != "" {
+		t.Fatalf("got recorded error %q, want none", recs[0].Err)
+	}
+
+	if want := []int{2, 3}; !slices.Equal(recs[0].Outputs, want) {
+		t.Fatalf("got recorded outputs %v, want %v", recs[0].Outputs, want)
+	}
+}
+
+// fakeTx is database/sql driver counting commits and rollbacks of transactions.
+type fakeTx struct {
+	commits   atomic.Int32
+	rollbacks atomic.Int32
+}
+
+func (d *fakeTx) Connect(ctx context.Context) (driver.Conn, error) { return d, nil }
+func (d *fakeTx) Driver() driver.Driver                            { return nil }
+func (d *fakeTx) Prepare(query string) (driver.Stmt, error)        { return nil, errors.ErrUnsupported }
+func (d *fakeTx) Close() error                                     { return nil }
+func (d *fakeTx) Begin() (driver.Tx, error)                        { return d, nil }
+func (d *fakeTx) Commit() error                                    { d.commits.Add(1); return nil }
+func (d *fakeTx) Rollback() error                                  { d.rollbacks.Add(1); return nil }
+
+func TestInTx(t *testing.T) {
+	errBroken := errors.New("broken")
+
+	tests := []struct {
+		name      string
+		err       error
+		wantErr   error
+		commits   int32
+		rollbacks int32
+	}{
+		{name: "success", commits: 1},
+		{name: "stop", err: ErrStop, commits: 1},
+		{name: "failure", err: errBroken, wantErr: errBroken, rollbacks: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &fakeTx{}
+
+			db := sql.OpenDB(d)
+			defer db.Close()
+
+			h := func(ctx context.Context, in int) (int, error) {
+				if TxFrom(ctx) == nil {
+					t.Error("got no transaction")
+				}
+
+				return in, tt.err
+			}
+
+			_, err := Execute(context.Background(), Pipeline[int]{InTx(db, nil, Pipeline[int]{h})}, 1)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if n := d.commits.Load(); n != tt.commits {
+				t.Fatalf("got %d commits, want %d", n, tt.commits)
+			}
+
+			if n := d.rollbacks.Load(); n != tt.rollbacks {
+				t.Fatalf("got %d rollbacks, want %d", n, tt.rollbacks)
+			}
+		})
+	}
+}
+
+func TestSentinelWrappers(t *testing.T) {
+	defer checkLeaks(t)()
+
+	key := func(v int) int { return v }
+
+	for _, sentinel := range []error{ErrSkipRest, ErrStop} {
+		cache := &MemoryCache[int, int]{}
+		store := &MemoryStore{}
+
+		var calls atomic.Int32
+
+		h := func(ctx context.Context, in int) (int, error) {
+			calls.Add(1)
+			return in + 1, sentinel
+		}
+
+		tests := []struct {
+			name  string
+			h     HandlerFunc[int]
+			calls int32
+			check func(t *testing.T)
+		}{
+			{
+				name:  "Hedge",
+				h:     Hedge(h, time.Hour, 2),
+				calls: 1,
+			},
+			{
+				name:  "Cached",
+				h:     Cached(h, key, time.Hour, cache),
+				calls: 1,
+				check: func(t *testing.T) {
+					if v, ok, _ := cache.Get(context.Background(), 1); !ok || v != 2 {
+						t.Fatalf("got cached %d, %t, want 2, true", v, ok)
+					}
+				},
+			},
+			{
+				name:  "Idempotent",
+				h:     Idempotent(h, func(v int) string { return "key" }, store),
+				calls: 1,
+				check: func(t *testing.T) {
+					if _, ok, _ := store.Load(context.Background(), "key"); !ok {
+						t.Fatal("the key has not been marked")
+					}
+				},
+			},
+			{
+				name:  "Compensate",
+				h:     Compensate(h, func(ctx context.Context, out int) error { return nil }),
+				calls: 1,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name+"/"+sentinel.Error(), func(t *testing.T) {
+				calls.Store(0)
+
+				var next atomic.Int32
+
+				after := func(ctx context.Context, in int) (int, error) {
+					next.Add(1)
+					return in, nil
+				}
+
+				out, err := Execute(context.Background(), Pipeline[int]{tt.h, after}, 1)
+				if err != nil || out != 2 {
+					t.Fatalf("got %d, %v, want 2, nil", out, err)
+				}
+
+				if n := next.Load(); n != 0 {
+					t.Fatalf("the next stage has been called %d times, want 0", n)
+				}
+
+				if n := calls.Load(); n != tt.calls {
+					t.Fatalf("got %d calls of handler, want %d", n, tt.calls)
+				}
+
+				if tt.check != nil {
+					tt.check(t)
+				}
+			})
+		}
+	}
+}
+
+func TestCompensateSkipRest(t *testing.T) {
+	errBroken := errors.New("broken")
+
+	var compensated []int
+
+	h := func(ctx context.Context, in int) (int, error) {
+		if in == 0 {
+			return in, ErrSkipRest
+		}
+
+		return in, errBroken
+	}
+
+	compensate := func(ctx context.Context, out int) error {
+		compensated = append(compensated, out)
+		return nil
+	}
+
+	_, err := Execute(context.Background(), Pipeline[[]int]{ForEach(Compensate(h, compensate))}, []int{0, 1})
+	if !errors.Is(err, errBroken) {
+		t.Fatalf("got error %v, want %v", err, errBroken)
+	}
+
+	if want := []int{0}; !slices.Equal(compensated, want) {
+		t.Fatalf("got compensated %v, want %v", compensated, want)
+	}
+}
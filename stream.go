@@ -0,0 +1,574 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultBuffer is the capacity of channels between streaming stages when no Buffer option is given.
+const DefaultBuffer = 16
+
+// ErrStreamRunning is returned by Stream.Run when the stream is already running.
+var ErrStreamRunning = errors.New("pipeline: stream is already running")
+
+// Source emits values into out until it is exhausted or ctx is done.
+// Every emitted value gets its own Metadata passed along with it through the stages.
+// Source must not close out, it is closed by the stream after Source returns.
+// Source must return after ctx is done, values it sends meanwhile are discarded.
+type Source[T any] func(ctx context.Context, out chan<- T) error
+
+// Sink consumes values produced by the last stage of a stream.
+type Sink[T any] func(ctx context.Context, in T) error
+
+// StageOption configures a stage of a stream.
+type StageOption func(*stageConfig)
+
+type stageConfig struct {
+	buffer  int
+	workers int
+	// maxWorkers is greater than workers for stages scaled by ScaleWorkers.
+	maxWorkers int
+	governor   *Governor
+}
+
+// Buffer sets capacity of the channel feeding the stage.
+// Senders block when the channel is full, so a slow stage slows down its producers
+// instead of accumulating values in memory.
+func Buffer(n int) StageOption {
+	if n < 0 {
+		panic("buffer value must not be negative!")
+	}
+
+	return func(c *stageConfig) {
+		c.buffer = n
+	}
+}
+
+// Workers sets number of routines processing values of the stage concurrently.
+// Values leaving a stage with more than one worker may be reordered.
+// Every worker gets its own scope, so handlers built with FromStage and PerWorker
+// are initialized once per worker.
+func Workers(n int) StageOption {
+	if n <= 0 {
+		panic("workers value must be greater than zero!")
+	}
+
+	return func(c *stageConfig) {
+		c.workers = n
+	}
+}
+
+// ScaleWorkers makes the stage scale its workers between min and max by depth of its input buffer.
+// Every DefaultScaleInterval a stage with at least half full buffer starts as many workers as needed to drain it
+// within the next interval given the observed latency of the stage, but at least one. It stops one idle worker
+// after the buffer has stayed empty for several intervals in a row, so short pauses of traffic do not make
+// the stage flap. The stage must have a buffer, see Buffer, Via panics otherwise.
+func ScaleWorkers(min, max int) StageOption {
+	if min <= 0 || max < min {
+		panic("workers bounds must satisfy 0 < min <= max!")
+	}
+
+	return func(c *stageConfig) {
+		c.workers = min
+		c.maxWorkers = max
+	}
+}
+
+// StageGovern makes governor limit the number of the stage workers processing values at once.
+func StageGovern(g *Governor) StageOption {
+	return func(c *stageConfig) {
+		c.governor = g
+	}
+}
+
+// BufferStats is a snapshot of a channel fill level.
+type BufferStats struct {
+	Len int
+	Cap int
+}
+
+// Stream is a pipeline which stages run concurrently and are connected by bounded channels.
+type Stream[T any] struct {
+	defaults []StageOption
+	stages   []streamStage[T]
+
+	mu      sync.Mutex
+	running bool
+	chans   []chan item[T]
+
+	health healthTracker
+}
+
+// item is a stream element with its metadata.
+type item[T any] struct {
+	v  T
+	md *Metadata
+	// skip is set when a stage has returned ErrSkipRest, following stages pass the element through.
+	skip bool
+	// stop is set when a stage has returned ErrStop, the stream stops after the element reaches the sink.
+	stop bool
+	// drop is set when a stage has returned ErrDropped, the element is not passed to the sink.
+	drop bool
+}
+
+type streamStage[T any] struct {
+	handler HandlerFunc[T]
+	config  stageConfig
+}
+
+// NewStream returns empty stream. Options are applied to every stage and to the output channel
+// before the stage's own options.
+func NewStream[T any](opts ...StageOption) *Stream[T] {
+	return &Stream[T]{defaults: opts}
+}
+
+// Via appends stage to the stream.
+func (s *Stream[T]) Via(handler HandlerFunc[T], opts ...StageOption) *Stream[T] {
+	c := s.config(opts)
+
+	// The depth of the buffer drives scaling, a stage without one would never scale up.
+	if c.maxWorkers > c.workers && c.buffer == 0 {
+		panic("stage scaled by ScaleWorkers must have a buffer!")
+	}
+
+	s.stages = append(s.stages, streamStage[T]{
+		handler: handler,
+		config:  c,
+	})
+
+	return s
+}
+
+func (s *Stream[T]) config(opts []StageOption) stageConfig {
+	c := stageConfig{buffer: DefaultBuffer, workers: 1}
+
+	for _, opt := range s.defaults {
+		opt(&c)
+	}
+
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// StallTimeout sets period without values reaching the sink after which Health reports the busy stream
+// as stalled, it is DefaultStallTimeout by default.
+func (s *Stream[T]) StallTimeout(d time.Duration) *Stream[T] {
+	if d <= 0 {
+		panic("timeout value must be greater than zero!")
+	}
+
+	s.health.timeout = d
+
+	return s
+}
+
+// Health returns state of the stream, it is stalled when values are in work but none has reached the sink
+// within the stall timeout.
+func (s *Stream[T]) Health() Health {
+	return s.health.report()
+}
+
+// OnPause sets hook called after the stream has been paused or resumed.
+func (s *Stream[T]) OnPause(fn func(paused bool)) *Stream[T] {
+	s.health.mu.Lock()
+	defer s.health.mu.Unlock()
+
+	s.health.onPause = fn
+
+	return s
+}
+
+// Pause stops pulling values from the source and waits until values in work have reached the sink
+// or ctx is done. The source is blocked meanwhile, the stream keeps running until Resume.
+// Pausing a stream which is not running makes its next run start paused.
+func (s *Stream[T]) Pause(ctx context.Context) error {
+	return s.health.pause(ctx)
+}
+
+// Resume continues pulling values from the source.
+func (s *Stream[T]) Resume() {
+	s.health.setPaused(false)
+}
+
+// Stages describes stages of the stream with their options.
+func (s *Stream[T]) Stages() []StageInfo {
+	stages := make([]StageInfo, len(s.stages))
+
+	for i, stage := range s.stages {
+		stages[i] = describeHandler(stage.handler)
+		stages[i].Index = i
+		stages[i].Options = map[string]any{
+			"buffer":  stage.config.buffer,
+			"workers": stage.config.workers,
+		}
+
+		if stage.config.maxWorkers > stage.config.workers {
+			stages[i].Options["max_workers"] = stage.config.maxWorkers
+		}
+	}
+
+	return stages
+}
+
+func (s *Stream[T]) String() string {
+	return formatStages(s.Stages())
+}
+
+// Buffers returns fill levels of the channels of the running stream.
+// The i-th entry is the input of the i-th stage, the last one is the input of the sink.
+// Returns nil when the stream is not running.
+func (s *Stream[T]) Buffers() []BufferStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return nil
+	}
+
+	stats := make([]BufferStats, len(s.chans))
+
+	for i, ch := range s.chans {
+		stats[i] = BufferStats{Len: len(ch), Cap: cap(ch)}
+	}
+
+	return stats
+}
+
+// Run pulls values from src, passes them through the stages and feeds results to sink.
+// It returns after all routines it has started have returned, including the source and workers
+// blocked on sending to the next stage, so canceling ctx leaves nothing running.
+// The routines are run by the Executor attached to ctx, a bounded one must be able to run all of them at once:
+// two for the source and, for every stage, its workers and one more. The first error stops the whole stream,
+// see also ErrStop.
+func (s *Stream[T]) Run(ctx context.Context, src Source[T], sink Sink[T]) error {
+	chans, err := s.start()
+	if err != nil {
+		return err
+	}
+	defer s.stop()
+
+	s.health.start()
+	defer s.health.set(HealthStopped)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel(err)
+		})
+	}
+
+	values := make(chan T)
+
+	wg.Add(2)
+	spawn(ctx, func() {
+		defer wg.Done()
+		defer close(values)
+
+		if err := src(ctx, values); err != nil {
+			fail(err)
+			return
+		}
+
+		s.health.set(HealthDraining)
+	})
+
+	spawn(ctx, func() {
+		defer wg.Done()
+		defer close(chans[0])
+
+		wrap(ctx, values, chans[0], &s.health)
+	})
+
+	for i, stage := range s.stages {
+		i, stage := i, stage
+
+		var sem *governorSemaphore
+
+		if stage.config.governor != nil {
+			sem = &governorSemaphore{g: stage.config.governor, n: max(stage.config.workers, stage.config.maxWorkers)}
+		}
+
+		worker := func(handler HandlerFunc[T], quit <-chan struct{}) {
+			ctx, sc := newScope(ctx, true)
+			sc.cancel = fail
+			ctx = withWorker(ctx, sc)
+
+			if err := runStage(ctx, i, handler, chans[i], chans[i+1], quit, sem); err != nil {
+				fail(err)
+			}
+
+			if err := sc.close(); err != nil {
+				fail(err)
+			}
+		}
+
+		wg.Add(1)
+
+		if stage.config.maxWorkers > stage.config.workers {
+			spawn(ctx, func() {
+				defer wg.Done()
+				defer close(chans[i+1])
+
+				scaleStage(ctx, stage.config, stage.handler, chans[i], worker)
+			})
+
+			continue
+		}
+
+		var workers sync.WaitGroup
+
+		for w := 0; w < stage.config.workers; w++ {
+			workers.Add(1)
+			spawn(ctx, func() {
+				defer workers.Done()
+
+				worker(stage.handler, nil)
+			})
+		}
+
+		spawn(ctx, func() {
+			defer wg.Done()
+
+			workers.Wait()
+			close(chans[i+1])
+		})
+	}
+
+	if err := drain(ctx, chans[len(chans)-1], sink, &s.health); err != nil {
+		fail(err)
+	}
+
+	wg.Wait()
+
+	if errors.Is(firstErr, ErrStop) {
+		return nil
+	}
+
+	if firstErr != nil {
+		s.health.fail(firstErr)
+		return firstErr
+	}
+
+	return cause(ctx)
+}
+
+func (s *Stream[T]) start() ([]chan item[T], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return nil, ErrStreamRunning
+	}
+
+	chans := make([]chan item[T], len(s.stages)+1)
+
+	for i, stage := range s.stages {
+		chans[i] = make(chan item[T], stage.config.buffer)
+	}
+
+	chans[len(s.stages)] = make(chan item[T], s.config(nil).buffer)
+
+	s.running = true
+	s.chans = chans
+
+	return chans, nil
+}
+
+func (s *Stream[T]) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running = false
+	s.chans = nil
+}
+
+// wrap attaches fresh metadata to every value, it does not take values while health is paused.
+func wrap[T any](ctx context.Context, in <-chan T, out chan<- item[T], health *healthTracker) {
+	// The source may be sending while it has not noticed ctx is done yet.
+	defer func() {
+		for range in {
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-health.ready():
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case v, ok := <-in:
+			if !ok {
+				return
+			}
+
+			if !health.begin(ctx.Done()) {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case out <- item[T]{v: v, md: &Metadata{}}:
+			}
+		}
+	}
+}
+
+// runStage handles values of in until it is closed, ctx is done or a value is received from quit.
+func runStage[T any](ctx context.Context, stage int, handler HandlerFunc[T], in <-chan item[T], out chan<- item[T], quit <-chan struct{}, sem *governorSemaphore) error {
+	hook := hookFrom(ctx)
+	labels := stageLabels(handler)
+
+	for {
+		if !sem.acquire(ctx, quit) {
+			return nil
+		}
+
+		more, err := stageStep(ctx, stage, hook, handler, labels, in, out, quit)
+
+		sem.release()
+
+		if !more || err != nil {
+			return err
+		}
+	}
+}
+
+// stageStep passes a value from in through handler to out, it returns false when the stage has to return.
+func stageStep[T any](ctx context.Context, stage int, hook Hook, handler HandlerFunc[T], labels map[string]string, in <-chan item[T], out chan<- item[T], quit <-chan struct{}) (more bool, err error) {
+	select {
+	case <-ctx.Done():
+		return false, nil
+	case <-quit:
+		return false, nil
+	case it, ok := <-in:
+		if !ok {
+			return false, nil
+		}
+
+		if !it.skip {
+			v, err := call(withMetadata(ctx, it.md), func(ctx context.Context, in T) (T, error) {
+				return handle(ctx, hook, stage, handler, labels, in)
+			}, it.v)
+
+			switch {
+			case errors.Is(err, ErrDropped):
+				it.skip, it.drop = true, true
+			case errors.Is(err, ErrStop):
+				it.skip, it.stop = true, true
+			case errors.Is(err, ErrSkipRest):
+				it.skip = true
+			case err != nil:
+				return false, stageError(ctx, stage, err, it.md, labels)
+			}
+
+			it.v = v
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case out <- it:
+			return true, nil
+		}
+	}
+}
+
+func drain[T any](ctx context.Context, in <-chan item[T], sink Sink[T], health *healthTracker) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case it, ok := <-in:
+			if !ok {
+				return nil
+			}
+
+			if it.drop {
+				health.end(nil)
+				continue
+			}
+
+			err := sink(withMetadata(ctx, it.md), it.v)
+			health.end(err)
+
+			if err != nil {
+				return err
+			}
+
+			if it.stop {
+				return ErrStop
+			}
+		}
+	}
+}
+
+// call executes handler recovering from its panic.
+func call[T any](ctx context.Context, handler HandlerFunc[T], in T) (out T, err error) {
+	if !recovering(ctx) {
+		return handler(ctx, in)
+	}
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = newPanicError(rec)
+		}
+	}()
+
+	return handler(ctx, in)
+}
+
+// FromSlice returns source emitting values of the slice in order.
+func FromSlice[T any](in []T) Source[T] {
+	return func(ctx context.Context, out chan<- T) error {
+		for _, v := range in {
+			select {
+			case <-ctx.Done():
+				return cause(ctx)
+			case out <- v:
+			}
+		}
+
+		return nil
+	}
+}
+
+// FromChan returns source emitting values received from ch until it is closed.
+func FromChan[T any](ch <-chan T) Source[T] {
+	return func(ctx context.Context, out chan<- T) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return cause(ctx)
+			case v, ok := <-ch:
+				if !ok {
+					return nil
+				}
+
+				select {
+				case <-ctx.Done():
+					return cause(ctx)
+				case out <- v:
+				}
+			}
+		}
+	}
+}
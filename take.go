@@ -0,0 +1,99 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+)
+
+// errTaken cancels the source of TakeSource after the limit is reached.
+var errTaken = errors.New("pipeline: take limit reached")
+
+// Take returns handler passing only the first n elements of its input further.
+func Take[T any](n int) HandlerFunc[[]T] {
+	if n < 0 {
+		panic("n value must not be negative!")
+	}
+
+	fn := func(ctx context.Context, in []T) (out []T, err error) {
+		if len(in) > n {
+			in = in[:n]
+		}
+
+		out = in
+
+		return out, nil
+	}
+
+	return fn
+}
+
+// TakeSource returns source emitting the first n values of src.
+// Once n values are emitted, src is canceled and its error is ignored.
+func TakeSource[T any](src Source[T], n int) Source[T] {
+	if n < 0 {
+		panic("n value must not be negative!")
+	}
+
+	fn := func(ctx context.Context, out chan<- T) error {
+		if n == 0 {
+			return nil
+		}
+
+		return forward(ctx, src, func(ctx context.Context, values <-chan T) error {
+			for taken := 0; taken < n; {
+				select {
+				case <-ctx.Done():
+					return cause(ctx)
+				case v, ok := <-values:
+					if !ok {
+						return nil
+					}
+
+					select {
+					case <-ctx.Done():
+						return cause(ctx)
+					case out <- v:
+						taken++
+					}
+				}
+			}
+
+			return errTaken
+		})
+	}
+
+	return fn
+}
+
+// forward runs src in background passing its values to consume. Error of consume cancels src,
+// errTaken stops src without an error. It returns after src has returned.
+func forward[T any](ctx context.Context, src Source[T], consume func(ctx context.Context, values <-chan T) error) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	values := make(chan T)
+	srcErr := make(chan error, 1)
+
+	spawn(ctx, func() {
+		defer close(values)
+		srcErr <- src(ctx, values)
+	})
+
+	err := consume(ctx, values)
+	if err != nil {
+		cancel(err)
+
+		for range values {
+		}
+	}
+
+	if serr := <-srcErr; err == nil {
+		err = serr
+	}
+
+	if errors.Is(err, errTaken) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,389 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Quota limits execution of values of a tenant, zero fields mean no limit.
+type Quota struct {
+	// Concurrency is the maximum number of values executed at once.
+	Concurrency int
+	// Rate is the maximum number of values started per second, Burst is the number of values
+	// which may be started at once after a pause, at least one.
+	Rate  float64
+	Burst int
+	// Queue is the maximum number of waiting values, Submit blocks while the queue is full.
+	Queue int
+}
+
+// TenantOption configures TenantExecutor.
+type TenantOption func(*tenantConfig)
+
+type tenantConfig struct {
+	quota  Quota
+	quotas func(tenant string) (Quota, bool)
+}
+
+// DefaultQuota sets quota of tenants without their own one.
+func DefaultQuota(q Quota) TenantOption {
+	return func(c *tenantConfig) {
+		c.quota = q
+	}
+}
+
+// TenantQuotas sets lookup of quotas of tenants, it is called when a tenant is seen first or again after idling.
+// Tenants it returns false for get the default quota.
+func TenantQuotas(quotas func(tenant string) (Quota, bool)) TenantOption {
+	return func(c *tenantConfig) {
+		c.quotas = quotas
+	}
+}
+
+// TenantStats is a snapshot of a tenant.
+type TenantStats struct {
+	Queued  int
+	Running int
+}
+
+// TenantExecutor executes pipeline for submitted values on a fixed set of workers enforcing quotas of tenants.
+// Workers take values of tenants in round-robin order, so a tenant with a long queue
+// does not delay values of the others. It is safe for concurrent use.
+type TenantExecutor[T any] struct {
+	pipeline Pipeline[T]
+	tenant   func(in T) string
+	cfg      tenantConfig
+
+	mu      sync.Mutex
+	tenants map[string]*tenantState[T]
+	// ring holds tenants with queued values in round-robin order, next is the index of the following one.
+	ring   []*tenantState[T]
+	next   int
+	queued int
+	// pruneAt is the number of known tenants at which idle ones are forgotten, it grows with the number
+	// of tenants left, so pruning scans them in amortized constant time per new tenant.
+	pruneAt int
+	// changed is closed and replaced when a value is queued or completed.
+	changed  chan struct{}
+	closed   bool
+	closeErr []error
+
+	wg sync.WaitGroup
+}
+
+type tenantState[T any] struct {
+	quota   Quota
+	queue   []*task[T]
+	running int
+	bucket  *tokenBucket
+	active  bool
+}
+
+// NewTenantExecutor starts executor with workers executing pipeline, tenant returns tenant of a value.
+func NewTenantExecutor[T any](pipeline Pipeline[T], tenant func(in T) string, workers int, opts ...TenantOption) *TenantExecutor[T] {
+	if workers <= 0 {
+		panic("workers value must be greater than zero!")
+	}
+
+	var cfg tenantConfig
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	e := &TenantExecutor[T]{
+		pipeline: pipeline,
+		tenant:   tenant,
+		cfg:      cfg,
+		tenants:  map[string]*tenantState[T]{},
+		pruneAt:  minTenantPrune,
+		changed:  make(chan struct{}),
+	}
+
+	e.wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go e.work()
+	}
+
+	return e
+}
+
+// minTenantPrune is the least number of known tenants at which idle ones are forgotten.
+const minTenantPrune = 64
+
+// Submit queues in for its tenant and waits for the result.
+// When ctx is done before a worker has taken the value, it is removed from the queue.
+func (e *TenantExecutor[T]) Submit(ctx context.Context, in T) (out T, err error) {
+	t := &task[T]{ctx: ctx, in: in, done: make(chan struct{})}
+
+	s, err := e.enqueue(ctx, e.tenant(in), t)
+	if err != nil {
+		return out, err
+	}
+
+	select {
+	case <-ctx.Done():
+		e.dequeue(s, t)
+		return out, cause(ctx)
+	case <-t.done:
+		return t.out, t.err
+	}
+}
+
+func (e *TenantExecutor[T]) enqueue(ctx context.Context, tenant string, t *task[T]) (*tenantState[T], error) {
+	e.mu.Lock()
+
+	for {
+		if e.closed {
+			e.mu.Unlock()
+			return nil, ErrExecutorClosed
+		}
+
+		s := e.state(tenant)
+
+		if s.quota.Queue <= 0 || len(s.queue) < s.quota.Queue {
+			s.queue = append(s.queue, t)
+			e.queued++
+
+			if !s.active {
+				s.active = true
+				e.ring = append(e.ring, s)
+			}
+
+			e.notify()
+			e.mu.Unlock()
+
+			return s, nil
+		}
+
+		changed := e.changed
+
+		e.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, cause(ctx)
+		case <-changed:
+		}
+
+		e.mu.Lock()
+	}
+}
+
+// dequeue removes t from the queue of tenant s unless a worker has taken it.
+func (e *TenantExecutor[T]) dequeue(s *tenantState[T], t *task[T]) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	i := slices.Index(s.queue, t)
+	if i < 0 {
+		return
+	}
+
+	s.queue = slices.Delete(s.queue, i, i+1)
+	e.queued--
+
+	if len(s.queue) == 0 {
+		e.deactivate(slices.Index(e.ring, s))
+	}
+
+	// A sender waiting for room in the queue of the tenant may proceed, Close may finish.
+	e.notify()
+}
+
+// deactivate removes tenant at index i of the ring keeping the round-robin order, must be called with locked mu.
+func (e *TenantExecutor[T]) deactivate(i int) {
+	e.ring[i].active = false
+	e.ring = slices.Delete(e.ring, i, i+1)
+
+	if i < e.next {
+		e.next--
+	}
+
+	if len(e.ring) > 0 {
+		e.next %= len(e.ring)
+	} else {
+		e.next = 0
+	}
+}
+
+// state returns state of tenant creating it if needed, must be called with locked mu.
+func (e *TenantExecutor[T]) state(tenant string) *tenantState[T] {
+	if s, ok := e.tenants[tenant]; ok {
+		return s
+	}
+
+	if len(e.tenants) >= e.pruneAt {
+		e.prune()
+		e.pruneAt = max(2*len(e.tenants), minTenantPrune)
+	}
+
+	q := e.cfg.quota
+
+	if e.cfg.quotas != nil {
+		if tq, ok := e.cfg.quotas(tenant); ok {
+			q = tq
+		}
+	}
+
+	s := &tenantState[T]{quota: q, bucket: newTokenBucket(q.Rate, q.Burst)}
+	e.tenants[tenant] = s
+
+	return s
+}
+
+// prune forgets idle tenants which rate limit has been restored, must be called with locked mu.
+func (e *TenantExecutor[T]) prune() {
+	now := time.Now()
+
+	for tenant, s := range e.tenants {
+		if !s.active && s.running == 0 && s.bucket.full(now) {
+			delete(e.tenants, tenant)
+		}
+	}
+}
+
+// notify wakes waiting workers and senders, must be called with locked mu.
+func (e *TenantExecutor[T]) notify() {
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+// Stats returns snapshot of known tenants.
+func (e *TenantExecutor[T]) Stats() map[string]TenantStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	stats := make(map[string]TenantStats, len(e.tenants))
+
+	for tenant, s := range e.tenants {
+		stats[tenant] = TenantStats{Queued: len(s.queue), Running: s.running}
+	}
+
+	return stats
+}
+
+// Close stops accepting new values, waits for queued ones and stops workers.
+// It returns errors of closing stages owned by workers.
+func (e *TenantExecutor[T]) Close() error {
+	e.mu.Lock()
+
+	if e.closed {
+		e.mu.Unlock()
+		return ErrExecutorClosed
+	}
+
+	e.closed = true
+	e.notify()
+
+	e.mu.Unlock()
+
+	e.wg.Wait()
+
+	return errors.Join(e.closeErr...)
+}
+
+func (e *TenantExecutor[T]) work() {
+	defer e.wg.Done()
+
+	s := &scope{}
+
+	defer func() {
+		if err := s.close(); err != nil {
+			e.mu.Lock()
+			e.closeErr = append(e.closeErr, err)
+			e.mu.Unlock()
+		}
+	}()
+
+	for {
+		e.mu.Lock()
+
+		ts, t, wait := e.pick(time.Now())
+		if t != nil {
+			e.mu.Unlock()
+			e.run(s, ts, t)
+
+			continue
+		}
+
+		if e.closed && e.queued == 0 {
+			e.mu.Unlock()
+			return
+		}
+
+		changed := e.changed
+
+		e.mu.Unlock()
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+
+			select {
+			case <-changed:
+			case <-timer.C:
+			}
+
+			timer.Stop()
+		} else {
+			<-changed
+		}
+	}
+}
+
+// pick takes the next task of tenants in round-robin order within their quotas, must be called with locked mu.
+// If there is none, it returns the time until a rate limited tenant may start a value or zero.
+func (e *TenantExecutor[T]) pick(now time.Time) (s *tenantState[T], t *task[T], wait time.Duration) {
+	for n := 0; n < len(e.ring); n++ {
+		i := (e.next + n) % len(e.ring)
+		s = e.ring[i]
+
+		if s.quota.Concurrency > 0 && s.running >= s.quota.Concurrency {
+			continue
+		}
+
+		if d := s.bucket.take(now, 1); d > 0 {
+			if wait == 0 || d < wait {
+				wait = d
+			}
+
+			continue
+		}
+
+		t = s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.running++
+		e.queued--
+
+		// The round-robin continues after the tenant.
+		e.next = i + 1
+
+		if len(s.queue) == 0 {
+			e.deactivate(i)
+		} else {
+			e.next %= len(e.ring)
+		}
+
+		// A sender waiting for room in the queue of the tenant may proceed.
+		e.notify()
+
+		return s, t, 0
+	}
+
+	return nil, nil, wait
+}
+
+func (e *TenantExecutor[T]) run(s *scope, ts *tenantState[T], t *task[T]) {
+	t.out, t.err = Execute(withWorker(t.ctx, s), e.pipeline, t.in)
+	close(t.done)
+
+	e.mu.Lock()
+	ts.running--
+	e.notify()
+	e.mu.Unlock()
+}
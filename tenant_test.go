@@ -0,0 +1,215 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tenantOf returns the tenant of values like "tenant/n".
+func tenantOf(v string) string {
+	tenant, _, _ := strings.Cut(v, "/")
+	return tenant
+}
+
+// waitStats waits until tenant has stats want.
+func waitStats[T any](t *testing.T, e *TenantExecutor[T], tenant string, want TenantStats) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+
+	for e.Stats()[tenant] != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("got stats %+v of tenant %s, want %+v", e.Stats()[tenant], tenant, want)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTenantExecutor(t *testing.T) {
+	tests := []struct {
+		name   string
+		quota  Quota
+		queue  []string
+		want   []string
+		failed []string
+		// timeout is the timeout of Submit of values queued after the blocking one, zero means none.
+		timeout time.Duration
+	}{
+		{
+			name:  "round-robin",
+			queue: []string{"a/1", "a/2", "a/3", "b/1", "c/1"},
+			want:  []string{"a/1", "b/1", "c/1", "a/2", "a/3"},
+		},
+		{
+			name:   "abandoned",
+			queue:  []string{"a/1", "b/1"},
+			failed: []string{"a/1", "b/1"},
+			// Values abandoned by their senders are removed from the queue and never run.
+			timeout: 100 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer checkLeaks(t)()
+
+			release := make(chan struct{})
+
+			var (
+				mu  sync.Mutex
+				ran []string
+			)
+
+			h := func(ctx context.Context, in string) (string, error) {
+				if in == "x/0" {
+					<-release
+					return in, nil
+				}
+
+				mu.Lock()
+				ran = append(ran, in)
+				mu.Unlock()
+
+				return in, nil
+			}
+
+			e := NewTenantExecutor(Pipeline[string]{h}, tenantOf, 1, DefaultQuota(tt.quota))
+
+			var wg sync.WaitGroup
+
+			submit := func(v string, timeout time.Duration) {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					ctx := context.Background()
+
+					if timeout > 0 {
+						var cancel context.CancelFunc
+						ctx, cancel = context.WithTimeout(ctx, timeout)
+						defer cancel()
+					}
+
+					_, err := e.Submit(ctx, v)
+
+					if failed := slices.Contains(tt.failed, v); failed != (err != nil) {
+						t.Errorf("got error %v of %s, want failure %t", err, v, failed)
+					}
+				}()
+			}
+
+			// The only worker is busy while the values are queued.
+			submit("x/0", 0)
+			waitStats(t, e, "x", TenantStats{Running: 1})
+
+			counts := map[string]int{}
+
+			for _, v := range tt.queue {
+				tenant := tenantOf(v)
+				counts[tenant]++
+
+				submit(v, tt.timeout)
+				waitStats(t, e, tenant, TenantStats{Queued: counts[tenant]})
+			}
+
+			if tt.timeout > 0 {
+				for tenant := range counts {
+					waitStats(t, e, tenant, TenantStats{})
+				}
+			}
+
+			close(release)
+			wg.Wait()
+
+			if err := e.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(ran, tt.want) {
+				t.Fatalf("got order %v, want %v", ran, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantQuota(t *testing.T) {
+	defer checkLeaks(t)()
+
+	var (
+		mu      sync.Mutex
+		running int
+		peak    int
+	)
+
+	h := func(ctx context.Context, in string) (string, error) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+
+		return in, nil
+	}
+
+	quotas := func(tenant string) (Quota, bool) {
+		return Quota{Concurrency: 2}, tenant == "limited"
+	}
+
+	e := NewTenantExecutor(Pipeline[string]{h}, tenantOf, 8, TenantQuotas(quotas))
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			if _, err := e.Submit(context.Background(), fmt.Sprintf("limited/%d", i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if peak > 2 {
+		t.Fatalf("got %d values of the tenant running at once, want at most 2", peak)
+	}
+
+	if _, err := e.Submit(context.Background(), "limited/0"); !errors.Is(err, ErrExecutorClosed) {
+		t.Fatalf("got error %v, want ErrExecutorClosed", err)
+	}
+}
+
+func TestTenantPrune(t *testing.T) {
+	e := NewTenantExecutor(Pipeline[string]{}, tenantOf, 1)
+	defer e.Close()
+
+	for i := 0; i < 1000; i++ {
+		if _, err := e.Submit(context.Background(), fmt.Sprintf("%d/0", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := len(e.Stats()); n > 2*minTenantPrune {
+		t.Fatalf("got %d known tenants, want idle ones forgotten", n)
+	}
+}
@@ -0,0 +1,72 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Timeout returns handler failing with context.DeadlineExceeded when handler has not completed within timeout.
+func Timeout[T any](handler HandlerFunc[T], timeout time.Duration) HandlerFunc[T] {
+	return TimeoutFallback(handler, timeout, nil)
+}
+
+// TimeoutOr returns handler passing def further when handler has not completed within timeout.
+// The substitution is reported to hooks as EventFallback.
+func TimeoutOr[T any](handler HandlerFunc[T], timeout time.Duration, def T) HandlerFunc[T] {
+	return TimeoutFallback(handler, timeout, func(ctx context.Context, in T) (T, error) {
+		return def, nil
+	})
+}
+
+// TimeoutFallback returns handler passing result of fallback further when handler has not completed within timeout.
+// The substitution is reported to hooks as EventFallback. Handler is abandoned after timeout,
+// it keeps running in background until it notices the canceled context.
+// Nil fallback makes the handler fail with context.DeadlineExceeded.
+func TimeoutFallback[T any](handler HandlerFunc[T], timeout time.Duration, fallback HandlerFunc[T]) HandlerFunc[T] {
+	type result struct {
+		out T
+		err error
+	}
+
+	fn := func(ctx context.Context, in T) (out T, err error) {
+		tctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		results := make(chan result, 1)
+
+		spawn(ctx, func() {
+			out, err := call(tctx, handler, in)
+			results <- result{out, err}
+		})
+
+		select {
+		case r := <-results:
+			if r.err == nil || !errors.Is(r.err, context.DeadlineExceeded) || ctx.Err() != nil {
+				return r.out, r.err
+			}
+
+			err = r.err
+
+		case <-tctx.Done():
+			if ctx.Err() != nil {
+				return out, cause(ctx)
+			}
+
+			err = tctx.Err()
+		}
+
+		if fallback == nil {
+			return out, err
+		}
+
+		emit(ctx, Event{Kind: EventFallback, Err: err})
+
+		return fallback(ctx, in)
+	}
+
+	clone := func() any { return TimeoutFallback(cloneHandler(handler), timeout, cloneHandler(fallback)) }
+
+	return describe(fn, describeInfo{middleware: fmt.Sprintf("Timeout(%s)", timeout), inner: handler, clone: clone})
+}
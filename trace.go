@@ -0,0 +1,57 @@
+package pipe
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// DefaultTraceLimit is the maximum length of values formatted by the default trace formatter.
+const DefaultTraceLimit = 256
+
+// Formatter renders a value for logs, it is the place to truncate or redact sensitive data.
+type Formatter[T any] func(v T) string
+
+// WithTrace returns ctx making runs executed with it log values entering every stage to logger at debug level.
+// Values of type T are rendered by format, the others and all values when format is nil are printed
+// with %+v and truncated to DefaultTraceLimit bytes. Nil logger means slog.Default().
+func WithTrace[T any](ctx context.Context, logger *slog.Logger, format Formatter[T]) context.Context {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	fn := func(ctx context.Context, e Event) {
+		if e.Kind != EventStageStart || !logger.Enabled(ctx, slog.LevelDebug) {
+			return
+		}
+
+		var value string
+
+		if v, ok := e.In.(T); ok && format != nil {
+			value = format(v)
+		} else {
+			value = truncate(fmt.Sprintf("%+v", e.In), DefaultTraceLimit)
+		}
+
+		attrs := []slog.Attr{
+			slog.Int("stage", e.Stage),
+			slog.String("value", value),
+		}
+
+		if e.CorrelationID != "" {
+			attrs = append(attrs, slog.String("correlation_id", e.CorrelationID))
+		}
+
+		logger.LogAttrs(ctx, slog.LevelDebug, "pipeline: stage input", attrs...)
+	}
+
+	return WithHook(ctx, fn)
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[:n] + "..."
+}
@@ -0,0 +1,179 @@
+package pipe
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Defaults of WatchDir.
+const (
+	DefaultWatchInterval = time.Second
+	DefaultWatchDebounce = time.Second
+)
+
+// WatchOption configures WatchDir.
+type WatchOption func(*watchConfig)
+
+type watchConfig struct {
+	interval time.Duration
+	debounce time.Duration
+	pattern  string
+	existing bool
+}
+
+// WatchInterval sets how often the directory is scanned.
+func WatchInterval(d time.Duration) WatchOption {
+	if d <= 0 {
+		panic("interval value must be greater than zero!")
+	}
+
+	return func(c *watchConfig) {
+		c.interval = d
+	}
+}
+
+// WatchDebounce sets how long a file must stay unchanged before it is emitted,
+// so files being written are emitted once after the writer has finished.
+func WatchDebounce(d time.Duration) WatchOption {
+	if d < 0 {
+		panic("debounce value must not be negative!")
+	}
+
+	return func(c *watchConfig) {
+		c.debounce = d
+	}
+}
+
+// WatchMatch makes the watcher emit only files which names match the shell pattern, see filepath.Match.
+func WatchMatch(pattern string) WatchOption {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		panic("pattern value must be a valid pattern!")
+	}
+
+	return func(c *watchConfig) {
+		c.pattern = pattern
+	}
+}
+
+// WatchExisting makes the watcher emit files which exist when it starts.
+func WatchExisting() WatchOption {
+	return func(c *watchConfig) {
+		c.existing = true
+	}
+}
+
+// WatchDir returns source emitting paths of files created or modified in dir until ctx is done.
+// The directory is polled, subdirectories are not watched. A file is emitted again after every change.
+func WatchDir(dir string, opts ...WatchOption) Source[string] {
+	cfg := watchConfig{
+		interval: DefaultWatchInterval,
+		debounce: DefaultWatchDebounce,
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	fn := func(ctx context.Context, out chan<- string) error {
+		files := map[string]*watchedFile{}
+
+		if err := scanDir(dir, cfg.pattern, files, time.Now(), cfg.existing); err != nil {
+			return err
+		}
+
+		ticker := time.NewTicker(cfg.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return cause(ctx)
+			case now := <-ticker.C:
+				if err := scanDir(dir, cfg.pattern, files, now, true); err != nil {
+					return err
+				}
+
+				for path, f := range files {
+					if !f.pending || now.Sub(f.changed) < cfg.debounce {
+						continue
+					}
+
+					select {
+					case <-ctx.Done():
+						return cause(ctx)
+					case out <- path:
+						f.pending = false
+					}
+				}
+			}
+		}
+	}
+
+	return fn
+}
+
+type watchedFile struct {
+	mod     time.Time
+	size    int64
+	changed time.Time
+	pending bool
+}
+
+// scanDir updates files with the state of dir, new and changed files become pending if track is true.
+func scanDir(dir, pattern string, files map[string]*watchedFile, now time.Time, track bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(entries))
+
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+
+		if pattern != "" {
+			if ok, _ := filepath.Match(pattern, e.Name()); !ok {
+				continue
+			}
+		}
+
+		info, err := e.Info()
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, e.Name())
+		seen[path] = true
+
+		f, ok := files[path]
+		if ok && f.mod.Equal(info.ModTime()) && f.size == info.Size() {
+			continue
+		}
+
+		if !ok {
+			f = &watchedFile{}
+			files[path] = f
+		}
+
+		f.mod = info.ModTime()
+		f.size = info.Size()
+		f.changed = now
+		f.pending = track
+	}
+
+	for path := range files {
+		if !seen[path] {
+			delete(files, path)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,315 @@
+package pipe
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSlowFactor is how many times a call has to exceed the baseline of its stage to be reported as slow.
+const DefaultSlowFactor = 4
+
+// DefaultWatchdogWarmUp is the number of calls of a stage which make up its baseline before slow calls are reported.
+const DefaultWatchdogWarmUp = 10
+
+// WatchdogOption configures Watchdog.
+type WatchdogOption func(*watchdogConfig)
+
+type watchdogConfig struct {
+	factor float64
+	stall  time.Duration
+	warmUp int
+}
+
+// WatchdogSlow sets how many times a call has to exceed the baseline of its stage to be reported as slow,
+// it is DefaultSlowFactor by default. Zero factor disables the detection of slow calls.
+func WatchdogSlow(factor float64) WatchdogOption {
+	if factor != 0 && factor <= 1 {
+		panic("factor value must be greater than one!")
+	}
+
+	return func(c *watchdogConfig) {
+		c.factor = factor
+	}
+}
+
+// WatchdogStall sets period without handled values after which a busy stage is reported as stalled,
+// it is DefaultStallTimeout by default. Zero period disables the detection of stalls.
+func WatchdogStall(d time.Duration) WatchdogOption {
+	if d < 0 {
+		panic("stall timeout value must not be negative!")
+	}
+
+	return func(c *watchdogConfig) {
+		c.stall = d
+	}
+}
+
+// WatchdogWarmUp sets the number of calls of a stage which make up its baseline before slow calls are reported,
+// it is DefaultWatchdogWarmUp by default.
+func WatchdogWarmUp(n int) WatchdogOption {
+	if n <= 0 {
+		panic("warm up value must be greater than zero!")
+	}
+
+	return func(c *watchdogConfig) {
+		c.warmUp = n
+	}
+}
+
+// Watchdog detects degradations of stages from stage events, attach its Hook with WithHook.
+// It reports EventSlow when a call of a stage has taken longer than the slow factor times the baseline:
+// the rolling average time of succeeded calls. It reports EventStalled once when a stage has been busy
+// without handling a value within the stall timeout. Events are reported to hooks of the run of the stage,
+// events of stalls are not bound to a value, so they carry neither input nor metadata.
+// Baselines are kept per hook returned by Hook, attach a hook of its own to every watched pipeline,
+// stalls are detected per run of Execute and per worker of Stream.
+type Watchdog struct {
+	cfg watchdogConfig
+
+	mu     sync.Mutex
+	hooks  int
+	stages map[watchKey]*watchedStage
+	runs   map[runWatchKey]*watchedRun
+}
+
+type watchKey struct {
+	hook  int
+	stage int
+}
+
+type runWatchKey struct {
+	watchKey
+	// run is the scope of the run.
+	run *scope
+}
+
+type watchedStage struct {
+	calls    int
+	baseline time.Duration
+	running  int
+	slow     int
+	stalled  int
+}
+
+// watchedRun is the state of a stage within a run, it is forgotten when the stage is no longer running.
+type watchedRun struct {
+	running  int
+	progress time.Time
+	reported bool
+	timer    *time.Timer
+	// ctx is the context of the last event of the stage, hooks of it receive events of stalls.
+	ctx context.Context
+}
+
+// StageWatch is the state of a stage watched by Watchdog.
+type StageWatch struct {
+	// Hook is the index of the hook of the stage in order of Hook calls.
+	Hook  int
+	Stage int
+	// Baseline is the rolling average time of succeeded calls.
+	Baseline time.Duration
+	// Running is the number of calls in progress.
+	Running int
+	// Slow and Stalled are the numbers of reported slow calls and stalls.
+	Slow    int
+	Stalled int
+}
+
+// NewWatchdog returns watchdog configured by opts.
+func NewWatchdog(opts ...WatchdogOption) *Watchdog {
+	cfg := watchdogConfig{
+		factor: DefaultSlowFactor,
+		stall:  DefaultStallTimeout,
+		warmUp: DefaultWatchdogWarmUp,
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Watchdog{cfg: cfg, stages: map[watchKey]*watchedStage{}, runs: map[runWatchKey]*watchedRun{}}
+}
+
+// Hook returns hook watching EventStageStart and EventStageDone events.
+// Every call returns a hook with baselines of its own.
+func (w *Watchdog) Hook() Hook {
+	w.mu.Lock()
+	hook := w.hooks
+	w.hooks++
+	w.mu.Unlock()
+
+	fn := func(ctx context.Context, e Event) {
+		switch e.Kind {
+		case EventStageStart:
+			w.start(ctx, hook, e)
+		case EventStageDone:
+			w.done(ctx, hook, e)
+		}
+	}
+
+	return fn
+}
+
+func (w *Watchdog) stage(k watchKey) *watchedStage {
+	s, ok := w.stages[k]
+	if !ok {
+		s = &watchedStage{}
+		w.stages[k] = s
+	}
+
+	return s
+}
+
+func (w *Watchdog) start(ctx context.Context, hook int, e Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	k := runWatchKey{watchKey{hook, e.Stage}, scopeFrom(ctx)}
+
+	r, ok := w.runs[k]
+	if !ok {
+		r = &watchedRun{progress: e.Time}
+		w.runs[k] = r
+	}
+
+	r.ctx = ctx
+	r.running++
+	w.stage(k.watchKey).running++
+
+	w.arm(k, r, w.cfg.stall-time.Since(r.progress))
+}
+
+func (w *Watchdog) done(ctx context.Context, hook int, e Event) {
+	w.mu.Lock()
+
+	k := runWatchKey{watchKey{hook, e.Stage}, scopeFrom(ctx)}
+	s := w.stage(k.watchKey)
+
+	if r, ok := w.runs[k]; ok {
+		r.ctx = ctx
+		r.progress = e.Time
+		r.reported = false
+		r.running--
+		s.running--
+
+		if r.running > 0 {
+			w.arm(k, r, w.cfg.stall)
+		} else {
+			w.forget(k, r)
+		}
+	}
+
+	baseline := s.baseline
+	slow := w.cfg.factor > 0 && s.calls >= w.cfg.warmUp && float64(e.Duration) > w.cfg.factor*float64(baseline)
+
+	if slow {
+		s.slow++
+	}
+
+	if e.Err == nil {
+		s.calls++
+
+		if s.baseline == 0 {
+			s.baseline = e.Duration
+		} else {
+			s.baseline = (7*s.baseline + e.Duration) / 8
+		}
+	}
+
+	w.mu.Unlock()
+
+	if slow {
+		emitTo(ctx, hookFrom(ctx), e.Stage, Event{
+			Kind:     EventSlow,
+			In:       e.In,
+			Out:      e.Out,
+			Duration: e.Duration,
+			Baseline: baseline,
+			Err:      e.Err,
+			Labels:   e.Labels,
+		})
+	}
+}
+
+// forget drops the state of the stage in the run which is no longer running, must be called with locked mu.
+func (w *Watchdog) forget(k runWatchKey, r *watchedRun) {
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+
+	r.ctx = nil
+	delete(w.runs, k)
+}
+
+// arm starts timer checking the stage for a stall after d unless it is running, must be called with locked mu.
+func (w *Watchdog) arm(k runWatchKey, r *watchedRun, d time.Duration) {
+	if w.cfg.stall == 0 || r.timer != nil || r.reported {
+		return
+	}
+
+	r.timer = time.AfterFunc(max(d, 0), func() {
+		w.check(k, r)
+	})
+}
+
+// check reports the stall of the stage or arms the timer again if the stage has made progress meanwhile.
+func (w *Watchdog) check(k runWatchKey, r *watchedRun) {
+	w.mu.Lock()
+
+	if w.runs[k] != r || r.running == 0 {
+		w.mu.Unlock()
+		return
+	}
+
+	r.timer = nil
+
+	idle := time.Since(r.progress)
+	if idle < w.cfg.stall {
+		w.arm(k, r, w.cfg.stall-idle)
+		w.mu.Unlock()
+
+		return
+	}
+
+	w.stage(k.watchKey).stalled++
+	r.reported = true
+	ctx := r.ctx
+
+	w.mu.Unlock()
+
+	if hook := hookFrom(ctx); hook != nil {
+		hook(ctx, Event{Kind: EventStalled, Time: time.Now(), Stage: k.stage, Duration: idle})
+	}
+}
+
+// Stats returns state of stages ordered by hook and stage index.
+func (w *Watchdog) Stats() []StageWatch {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := make([]StageWatch, 0, len(w.stages))
+
+	for k, s := range w.stages {
+		stats = append(stats, StageWatch{
+			Hook:     k.hook,
+			Stage:    k.stage,
+			Baseline: s.baseline,
+			Running:  s.running,
+			Slow:     s.slow,
+			Stalled:  s.stalled,
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Hook != stats[j].Hook {
+			return stats[i].Hook < stats[j].Hook
+		}
+
+		return stats[i].Stage < stats[j].Stage
+	})
+
+	return stats
+}
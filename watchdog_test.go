@@ -0,0 +1,131 @@
+package pipe
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchdogSlow(t *testing.T) {
+	w := NewWatchdog(WatchdogWarmUp(3), WatchdogStall(0))
+
+	var (
+		mu   sync.Mutex
+		slow []int
+	)
+
+	report := func(ctx context.Context, e Event) {
+		if e.Kind == EventSlow {
+			mu.Lock()
+			slow = append(slow, int(e.Duration/time.Millisecond))
+			mu.Unlock()
+		}
+	}
+
+	fast, heavy := w.Hook(), w.Hook()
+	ctx, _ := newScope(WithHook(context.Background(), report), false)
+
+	call := func(hook Hook, d time.Duration) {
+		hook(ctx, Event{Kind: EventStageStart, Time: time.Now()})
+		hook(ctx, Event{Kind: EventStageDone, Time: time.Now(), Duration: d})
+	}
+
+	calls := []struct {
+		hook Hook
+		d    time.Duration
+	}{
+		{hook: fast, d: time.Millisecond},
+		{hook: fast, d: time.Millisecond},
+		{hook: fast, d: time.Millisecond},
+		// Stages of another pipeline have baselines of their own.
+		{hook: heavy, d: 100 * time.Millisecond},
+		{hook: heavy, d: 100 * time.Millisecond},
+		{hook: heavy, d: 100 * time.Millisecond},
+		{hook: heavy, d: 120 * time.Millisecond},
+		{hook: fast, d: 50 * time.Millisecond},
+	}
+
+	for _, c := range calls {
+		call(c.hook, c.d)
+	}
+
+	if len(slow) != 1 || slow[0] != 50 {
+		t.Fatalf("got slow calls %v ms, want [50]", slow)
+	}
+
+	stats := w.Stats()
+
+	if len(stats) != 2 || stats[0].Hook != 0 || stats[1].Hook != 1 {
+		t.Fatalf("got stats %+v, want stage 0 of both hooks", stats)
+	}
+
+	if stats[0].Slow != 1 || stats[1].Slow != 0 || stats[1].Baseline < 100*time.Millisecond {
+		t.Fatalf("got stats %+v, want the slow call of the first hook only", stats)
+	}
+}
+
+func TestWatchdogStall(t *testing.T) {
+	defer checkLeaks(t)()
+
+	w := NewWatchdog(WatchdogStall(10 * time.Millisecond))
+
+	stalled := make(chan Event, 1)
+
+	report := func(ctx context.Context, e Event) {
+		if e.Kind == EventStalled {
+			stalled <- e
+		}
+	}
+
+	hook := w.Hook()
+	ctx := WithHook(context.Background(), func(ctx context.Context, e Event) {
+		hook(ctx, e)
+		report(ctx, e)
+	})
+
+	release := make(chan struct{})
+
+	wait := func(ctx context.Context, in int) (int, error) {
+		<-release
+		return in, nil
+	}
+
+	inc := func(ctx context.Context, in int) (int, error) { return in + 1, nil }
+
+	done := make(chan error)
+
+	go func() {
+		_, err := Execute(ctx, Pipeline[int]{inc, wait}, 0)
+		done <- err
+	}()
+
+	select {
+	case e := <-stalled:
+		if e.Stage != 1 {
+			t.Fatalf("got stall of stage %d, want 1", e.Stage)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the stall has not been reported")
+	}
+
+	close(release)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	w.mu.Lock()
+	runs := len(w.runs)
+	w.mu.Unlock()
+
+	if runs != 0 {
+		t.Fatalf("got %d watched runs after the run has finished, want none", runs)
+	}
+
+	for _, s := range w.Stats() {
+		if s.Running != 0 {
+			t.Fatalf("got %d running calls of stage %d, want none", s.Running, s.Stage)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureHeader is the request header holding HMAC-SHA256 signature of the body set by WebhookSign,
+// the value is "sha256=" followed by hex digest.
+const SignatureHeader = "X-Pipe-Signature"
+
+// ErrCircuitOpen is returned by Webhook.Send while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("pipeline: circuit open")
+
+// WebhookOption configures Webhook.
+type WebhookOption func(*webhookConfig)
+
+type webhookConfig struct {
+	client   *http.Client
+	header   http.Header
+	key      []byte
+	attempts int
+	retry    []RetryOption
+	failures int
+	cooldown time.Duration
+}
+
+// WebhookClient sets client used for requests, http.DefaultClient is used by default.
+func WebhookClient(client *http.Client) WebhookOption {
+	return func(c *webhookConfig) {
+		c.client = client
+	}
+}
+
+// WebhookHeader sets header of requests, e.g. Content-Type which is application/octet-stream by default.
+func WebhookHeader(key, value string) WebhookOption {
+	return func(c *webhookConfig) {
+		c.header.Set(key, value)
+	}
+}
+
+// WebhookSign makes requests signed with HMAC-SHA256 of the body using key, see SignatureHeader.
+func WebhookSign(key []byte) WebhookOption {
+	return func(c *webhookConfig) {
+		c.key = key
+	}
+}
+
+// WebhookRetry makes failed requests repeated up to attempts times in total.
+// Responses with 4xx status except 429 are not retried.
+func WebhookRetry(attempts int, opts ...RetryOption) WebhookOption {
+	if attempts <= 0 {
+		panic("attempts value must be greater than zero!")
+	}
+
+	return func(c *webhookConfig) {
+		c.attempts = attempts
+		c.retry = opts
+	}
+}
+
+// WebhookBreaker makes Webhook fail fast with ErrCircuitOpen for cooldown after failures sends in a row have failed.
+// After cooldown a single send is let through, its success closes the circuit.
+func WebhookBreaker(failures int, cooldown time.Duration) WebhookOption {
+	if failures <= 0 {
+		panic("failures value must be greater than zero!")
+	}
+
+	if cooldown <= 0 {
+		panic("cooldown value must be greater than zero!")
+	}
+
+	return func(c *webhookConfig) {
+		c.failures = failures
+		c.cooldown = cooldown
+	}
+}
+
+// WebhookError is the error of a response with unexpected status.
+type WebhookError struct {
+	Status  int
+	Message string
+}
+
+func (e *WebhookError) Error() string {
+	return fmt.Sprintf("pipeline: webhook: %d: %s", e.Status, e.Message)
+}
+
+// Retryable reports whether the request may succeed when it is repeated: on 5xx and 429 statuses.
+func (e *WebhookError) Retryable() bool {
+	return e.Status >= http.StatusInternalServerError || e.Status == http.StatusTooManyRequests
+}
+
+// Webhook POSTs batches of values encoded by codec to URL, any 2xx status is success.
+// Use Sink for batching. Webhook is safe for concurrent use.
+type Webhook[T any] struct {
+	url   string
+	codec Codec[[]T]
+	cfg   webhookConfig
+	post  HandlerFunc[[]byte]
+
+	mu       sync.Mutex
+	failed   int
+	openTill time.Time
+	probing  bool
+}
+
+// NewWebhook returns webhook posting to url.
+func NewWebhook[T any](url string, codec Codec[[]T], opts ...WebhookOption) *Webhook[T] {
+	cfg := webhookConfig{
+		client:   http.DefaultClient,
+		header:   http.Header{"Content-Type": {"application/octet-stream"}},
+		attempts: 1,
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	w := &Webhook[T]{url: url, codec: codec, cfg: cfg}
+
+	w.post = func(ctx context.Context, body []byte) ([]byte, error) {
+		return body, w.do(ctx, body)
+	}
+
+	if cfg.attempts > 1 {
+		w.post = Retry(w.post, cfg.attempts, cfg.retry...)
+	}
+
+	return w
+}
+
+// Send posts batch.
+func (w *Webhook[T]) Send(ctx context.Context, batch []T) error {
+	body, err := w.codec.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	if !w.allow() {
+		return ErrCircuitOpen
+	}
+
+	_, err = w.post(ctx, body)
+
+	w.record(err)
+
+	return err
+}
+
+// Sink returns writer sending values by batches of size, see NewBatchWriter.
+func (w *Webhook[T]) Sink(size int, opts ...WriterOption) *BatchWriter[T] {
+	return NewBatchWriter(w.Send, size, opts...)
+}
+
+func (w *Webhook[T]) do(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+
+	req.Header = w.cfg.header.Clone()
+
+	if w.cfg.key != nil {
+		mac := hmac.New(sha256.New, w.cfg.key)
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := w.cfg.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	return &WebhookError{Status: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+}
+
+// allow reports whether a send may be started, it lets a single probe through after cooldown.
+func (w *Webhook[T]) allow() bool {
+	if w.cfg.failures == 0 {
+		return true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failed < w.cfg.failures {
+		return true
+	}
+
+	if w.probing || time.Now().Before(w.openTill) {
+		return false
+	}
+
+	w.probing = true
+
+	return true
+}
+
+// record accounts the result of a send for the circuit breaker.
+func (w *Webhook[T]) record(err error) {
+	if w.cfg.failures == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.probing = false
+
+	if err == nil {
+		w.failed = 0
+		return
+	}
+
+	w.failed++
+
+	if w.failed >= w.cfg.failures {
+		w.openTill = time.Now().Add(w.cfg.cooldown)
+	}
+}
@@ -0,0 +1,155 @@
+package pipe
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWebhook(t *testing.T) {
+	key := []byte("secret")
+
+	var (
+		requests atomic.Int32
+		statuses []int
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+
+		body, _ := io.ReadAll(r.Body)
+
+		mac := hmac.New(sha256.New, key)
+		mac.Write(body)
+
+		if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); r.Header.Get(SignatureHeader) != want {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+
+		status := http.StatusOK
+		if n <= len(statuses) {
+			status = statuses[n-1]
+		}
+
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	retry := WebhookRetry(3, RetryDelay(time.Millisecond))
+
+	tests := []struct {
+		name     string
+		opts     []WebhookOption
+		statuses []int
+		requests int32
+		status   int
+	}{
+		{name: "signed", opts: []WebhookOption{WebhookSign(key)}, requests: 1},
+		{name: "unsigned", requests: 1, status: http.StatusUnauthorized},
+		{name: "wrong key", opts: []WebhookOption{WebhookSign([]byte("guess"))}, requests: 1, status: http.StatusUnauthorized},
+		{name: "retried", opts: []WebhookOption{WebhookSign(key), retry}, statuses: []int{500, 429}, requests: 3},
+		{name: "retries exhausted", opts: []WebhookOption{WebhookSign(key), retry}, statuses: []int{500, 502, 503}, requests: 3, status: 503},
+		{name: "not retried", opts: []WebhookOption{WebhookSign(key), retry}, statuses: []int{400}, requests: 1, status: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests.Store(0)
+			statuses = tt.statuses
+
+			w := NewWebhook(srv.URL, JSONCodec[[]int](), tt.opts...)
+
+			err := w.Send(context.Background(), []int{1, 2})
+
+			if tt.status == 0 && err != nil {
+				t.Fatalf("got error %v, want nil", err)
+			}
+
+			if tt.status != 0 {
+				var we *WebhookError
+				if !errors.As(err, &we) || we.Status != tt.status {
+					t.Fatalf("got error %v, want status %d", err, tt.status)
+				}
+			}
+
+			if n := requests.Load(); n != tt.requests {
+				t.Fatalf("got %d requests, want %d", n, tt.requests)
+			}
+		})
+	}
+}
+
+func TestWebhookBreaker(t *testing.T) {
+	var (
+		sent    atomic.Int32
+		healthy atomic.Bool
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent.Add(1)
+
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	const cooldown = 50 * time.Millisecond
+
+	w := NewWebhook(srv.URL, JSONCodec[[]int](), WebhookBreaker(2, cooldown))
+
+	// send checks the error of a send, status is the expected status of the response, zero is success.
+	send := func(status int, requests int32) {
+		t.Helper()
+
+		sent.Store(0)
+
+		err := w.Send(context.Background(), []int{1})
+
+		var we *WebhookError
+
+		switch {
+		case requests == 0:
+			if !errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("got error %v, want %v", err, ErrCircuitOpen)
+			}
+		case status == 0:
+			if err != nil {
+				t.Fatalf("got error %v, want nil", err)
+			}
+		case !errors.As(err, &we) || we.Status != status:
+			t.Fatalf("got error %v, want status %d", err, status)
+		}
+
+		if n := sent.Load(); n != requests {
+			t.Fatalf("got %d requests, want %d", n, requests)
+		}
+	}
+
+	failed := http.StatusInternalServerError
+
+	// Two failures in a row open the circuit.
+	send(failed, 1)
+	send(failed, 1)
+	send(0, 0)
+
+	// The failed probe after cooldown opens it again.
+	time.Sleep(cooldown)
+	send(failed, 1)
+	send(0, 0)
+
+	// The succeeded probe closes it.
+	healthy.Store(true)
+	time.Sleep(cooldown)
+	send(0, 1)
+	send(0, 1)
+}
@@ -0,0 +1,236 @@
+package pipe
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/WinPooh32/pipe/backoff"
+)
+
+// WriterOption configures BatchWriter.
+type WriterOption func(*writerConfig)
+
+type writerConfig struct {
+	maxLatency time.Duration
+	retries    int
+	backoff    backoff.Backoff
+}
+
+// MaxLatency makes BatchWriter flush a partial batch when its oldest value has waited for d.
+func MaxLatency(d time.Duration) WriterOption {
+	if d <= 0 {
+		panic("latency value must be greater than zero!")
+	}
+
+	return func(c *writerConfig) {
+		c.maxLatency = d
+	}
+}
+
+// FlushRetries makes BatchWriter repeat failed flush up to n times waiting delay between attempts.
+func FlushRetries(n int, delay time.Duration) WriterOption {
+	return FlushBackoff(n, backoff.Constant(delay))
+}
+
+// FlushBackoff makes BatchWriter repeat failed flush up to n times pausing according to b.
+func FlushBackoff(n int, b backoff.Backoff) WriterOption {
+	if n < 0 {
+		panic("retries value must not be negative!")
+	}
+
+	return func(c *writerConfig) {
+		c.retries = n
+		c.backoff = b
+	}
+}
+
+// BatchWriter accumulates values and writes them by batches with flush, e.g. by a multi-row insert.
+// It is a Sink with Write and a Stage passing values further unchanged with FromStage,
+// the stage flushes the rest of values on Close. BatchWriter is safe for concurrent use.
+type BatchWriter[T any] struct {
+	flush func(ctx context.Context, batch []T) error
+	size  int
+	cfg   writerConfig
+
+	// flushing serializes flushes, so batches are written in order.
+	flushing sync.Mutex
+
+	mu    sync.Mutex
+	buf   []T
+	timer *time.Timer
+	err   error
+}
+
+// NewBatchWriter returns writer calling flush with batches of size values.
+func NewBatchWriter[T any](flush func(ctx context.Context, batch []T) error, size int, opts ...WriterOption) *BatchWriter[T] {
+	if size <= 0 {
+		panic("size value must be greater than zero!")
+	}
+
+	var cfg writerConfig
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &BatchWriter[T]{
+		flush: flush,
+		size:  size,
+		cfg:   cfg,
+		buf:   make([]T, 0, size),
+	}
+}
+
+// Write adds value to the current batch and flushes the batch when it is full.
+// It returns error of the previous background flush if any.
+func (w *BatchWriter[T]) Write(ctx context.Context, v T) error {
+	w.mu.Lock()
+
+	if err := w.takeErr(); err != nil {
+		w.mu.Unlock()
+		return err
+	}
+
+	w.buf = append(w.buf, v)
+	full := len(w.buf) >= w.size
+
+	if !full {
+		w.arm()
+	}
+
+	w.mu.Unlock()
+
+	if full {
+		return w.flushBuffered(ctx, false)
+	}
+
+	return nil
+}
+
+// Flush writes buffered values.
+func (w *BatchWriter[T]) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	err := w.takeErr()
+	w.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return w.flushBuffered(ctx, true)
+}
+
+func (w *BatchWriter[T]) Init(ctx context.Context) error {
+	return nil
+}
+
+func (w *BatchWriter[T]) Handle(ctx context.Context, in T) (out T, err error) {
+	if err := w.Write(ctx, in); err != nil {
+		return out, err
+	}
+
+	return in, nil
+}
+
+// Close flushes buffered values.
+func (w *BatchWriter[T]) Close() error {
+	return w.Flush(context.Background())
+}
+
+func (w *BatchWriter[T]) flushLate() {
+	w.mu.Lock()
+	w.timer = nil
+	w.mu.Unlock()
+
+	err := w.flushBuffered(context.Background(), true)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *BatchWriter[T]) takeErr() error {
+	err := w.err
+	w.err = nil
+
+	return err
+}
+
+// arm starts timer flushing the buffered values after the max latency, must be called with locked mu.
+func (w *BatchWriter[T]) arm() {
+	if w.cfg.maxLatency > 0 && w.timer == nil && len(w.buf) > 0 {
+		w.timer = time.AfterFunc(w.cfg.maxLatency, w.flushLate)
+	}
+}
+
+// flushBuffered writes full batches of buffered values, all makes it write the last partial batch too.
+// Batches are taken out of the buffer, so writes do not wait for flush and its retries. A failed batch
+// is put back in front of the buffer.
+func (w *BatchWriter[T]) flushBuffered(ctx context.Context, all bool) error {
+	w.flushing.Lock()
+	defer w.flushing.Unlock()
+
+	for {
+		w.mu.Lock()
+
+		n := len(w.buf)
+		if n == 0 || (!all && n < w.size) {
+			w.mu.Unlock()
+			return nil
+		}
+
+		batch := w.buf[:min(n, w.size)]
+		w.buf = append(make([]T, 0, w.size), w.buf[len(batch):]...)
+
+		if len(w.buf) == 0 && w.timer != nil {
+			w.timer.Stop()
+			w.timer = nil
+		}
+
+		w.mu.Unlock()
+
+		if err := w.send(ctx, batch); err != nil {
+			w.mu.Lock()
+			w.buf = append(batch, w.buf...)
+			w.arm()
+			w.mu.Unlock()
+
+			return err
+		}
+	}
+}
+
+// send calls flush with batch repeating it according to the retry options.
+func (w *BatchWriter[T]) send(ctx context.Context, batch []T) (err error) {
+	var delay time.Duration
+
+	for attempt := 0; ; attempt++ {
+		err = w.flush(ctx, batch)
+		if err == nil || attempt == w.cfg.retries {
+			return err
+		}
+
+		delay = w.cfg.backoff.Next(attempt+1, delay)
+
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return cause(ctx)
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,98 @@
+package pipe
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatchWriterWriteDuringRetries(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]int
+	)
+
+	failed := false
+
+	flush := func(ctx context.Context, batch []int) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !failed {
+			failed = true
+			return errors.New("unavailable")
+		}
+
+		batches = append(batches, append([]int(nil), batch...))
+
+		return nil
+	}
+
+	w := NewBatchWriter(flush, 2, FlushRetries(1, 200*time.Millisecond))
+
+	done := make(chan error, 1)
+
+	go func() {
+		w.Write(context.Background(), 1)
+		done <- w.Write(context.Background(), 2)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+
+	if err := w.Write(context.Background(), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if d := time.Since(start); d > 100*time.Millisecond {
+		t.Fatalf("write has waited %s for the retried flush", d)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(batches) != 2 || batches[0][0] != 1 || batches[0][1] != 2 || batches[1][0] != 3 {
+		t.Fatalf("got batches %v, want [[1 2] [3]]", batches)
+	}
+}
+
+func TestBatchWriterLatencyAfterFailure(t *testing.T) {
+	flushed := make(chan []int, 2)
+	calls := 0
+
+	flush := func(ctx context.Context, batch []int) error {
+		if calls++; calls == 1 {
+			return errors.New("unavailable")
+		}
+
+		flushed <- append([]int(nil), batch...)
+
+		return nil
+	}
+
+	w := NewBatchWriter(flush, 10, MaxLatency(20*time.Millisecond))
+
+	if err := w.Write(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case batch := <-flushed:
+		if len(batch) != 1 || batch[0] != 1 {
+			t.Fatalf("got batch %v, want [1]", batch)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("values have not been flushed again after the failed flush")
+	}
+}